@@ -10,9 +10,13 @@ import (
 	"github.com/golobby/dotenv/v2/pkg/encoder"
 )
 
+// Implemented by types that decode their own values; see `decoder.Unmarshaler`.
+type Unmarshaler = decoder.Unmarshaler
+
 // NewDecoder creates a new instance of decoder.Decoder using a byte slice or file descriptor.
 func NewDecoder[T ~[]byte | ~*bytes.Buffer | ~*os.File | ~*bytes.Reader](data T) *decoder.Decoder {
 	dec := &decoder.Decoder{}
+	dec.Opts = decoder.DefaultOpts()
 	var src io.Reader
 
 	//Go's generics cannot inference interfaces if 2+ cases fall thru to the same statement; feel free to dedupe the cases for buffer, file, and reader if and when the Go team fixes this
@@ -23,6 +27,7 @@ func NewDecoder[T ~[]byte | ~*bytes.Buffer | ~*os.File | ~*bytes.Reader](data T)
 		src = v
 	case *os.File:
 		src = v
+		dec.Name = v.Name()
 	case *bytes.Reader:
 		src = v
 	default:
@@ -0,0 +1,343 @@
+// Package cst parses dot env (.env) documents into a lossless concrete syntax tree.
+// Every comment, blank line, quote style, `export` keyword and spacing choice is kept, so printing a tree
+// reproduces its source byte for byte, and editing an entry leaves every other line untouched.
+package cst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+	"github.com/golobby/dotenv/v2/pkg/lexer"
+)
+
+// Byte order mark of UTF-8 sources; it is kept aside and written back when printing.
+var bomUTF8 = []byte{0xEF, 0xBB, 0xBF}
+
+// Represents the kind of a node.
+type Kind int
+
+const (
+	Blank   Kind = iota //A line holding nothing but whitespace.
+	Comment             //A line holding nothing but a comment.
+	Entry               //A key/value entry, possibly spanning several lines and followed by a comment.
+	Include             //An include directive, `#include path` or `@include path`.
+	Section             //A section header, `[name]` or `[name : parent]`, possibly followed by a comment.
+)
+
+// Represents a piece of the source text of a node.
+type Part struct {
+	Trivia bool        //Whether the part is text between tokens: whitespace, line terminators, continuations or ignored text.
+	Token  lexer.Token //The token held by the part; unset for trivia.
+	Raw    string      //Source text of the part; values include their quotes, heredocs their marker, body and delimiter.
+}
+
+// Represents a logical line of a document, along with its line terminator.
+type Node struct {
+	Kind  Kind
+	Line  int    //Line the node starts on.
+	Parts []Part //Source text of the node, split into tokens and trivia.
+}
+
+// Represents a parsed dot env (.env) document.
+type Document struct {
+	BOM   bool //Whether the source started with a UTF-8 byte order mark.
+	Nodes []*Node
+
+	rules   dialect.Rules
+	newline string //Line terminator used for new lines; the first one found in the source.
+}
+
+// Parse reads a UTF-8 dot env (.env) document with the given syntax rules.
+func Parse(src io.Reader, rules dialect.Rules) (*Document, error) {
+	b, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("dotenv: error when reading file; err: %v", err)
+	}
+
+	doc := &Document{rules: rules, newline: "\n"}
+	if bytes.HasPrefix(b, bomUTF8) {
+		doc.BOM = true
+		b = b[len(bomUTF8):]
+	}
+
+	//Transcoding would not round-trip; UTF-16 and UTF-32 sources hold NUL bytes or start with their BOM
+	if bytes.IndexByte(b, 0) >= 0 || bytes.HasPrefix(b, []byte{0xFF, 0xFE}) || bytes.HasPrefix(b, []byte{0xFE, 0xFF}) {
+		return nil, errors.New("dotenv: only UTF-8 documents can be parsed into a syntax tree")
+	}
+
+	if i := bytes.IndexAny(b, "\r\n"); i >= 0 {
+		doc.newline = string(b[i : i+1])
+		if bytes.HasPrefix(b[i:], []byte("\r\n")) {
+			doc.newline = "\r\n"
+		}
+	}
+
+	doc.Nodes, err = parse(b, rules, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// parse splits the given source into nodes; the source starts on the given line of the document.
+func parse(b []byte, rules dialect.Rules, line int) ([]*Node, error) {
+	//Record where each physical line starts; LF, CRLF and lone CR end a line, as they do for the scanner
+	starts := []int{0}
+	for i := 0; i < len(b); i++ {
+		if b[i] == '\r' && i+1 < len(b) && b[i+1] == '\n' {
+			i++
+		}
+		if b[i] == '\r' || b[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	if starts[len(starts)-1] != len(b) {
+		starts = append(starts, len(b))
+	}
+
+	//Positions refer to the normalized source, whose lines and columns match those of the original, except that
+	//the scanner drops a byte order mark left at the start; it is kept as trivia of the first node
+	bom := 0
+	if bytes.HasPrefix(b, bomUTF8) {
+		bom = len(bomUTF8)
+	}
+	offset := func(p lexer.Position) int {
+		if p.Line == 1 {
+			return bom + p.Column - 1
+		}
+		return starts[p.Line-1] + p.Column - 1
+	}
+
+	nodes := []*Node{}
+	var cur *Node
+	last := 0   //Last physical line covered by the current node.
+	cursor := 0 //Offset up to which the source has been assigned to parts.
+	trivia := func(end int) {
+		if end > cursor {
+			cur.Parts = append(cur.Parts, Part{Trivia: true, Raw: string(b[cursor:end])})
+			cursor = end
+		}
+	}
+
+	s := lexer.NewScanner(bytes.NewReader(b), rules)
+	s.Includes = true
+	s.Sections = true
+	prev := lexer.Blank
+	for s.Scan() {
+		t := s.Token()
+
+		//Blank lines, comment lines and entries each start a node; an inline comment belongs to its entry
+		begins := cur == nil || t.Kind == lexer.Blank || t.Kind == lexer.Export || t.Kind == lexer.Include || t.Kind == lexer.OptionalInclude || t.Kind == lexer.Section ||
+			(t.Kind == lexer.Key && prev != lexer.Export) || (t.Kind == lexer.Comment && t.Pos.Line > last)
+		if begins {
+			if cur != nil {
+				trivia(starts[last])
+			}
+
+			kind := Entry
+			switch t.Kind {
+			case lexer.Blank:
+				kind = Blank
+			case lexer.Comment:
+				kind = Comment
+			case lexer.Include, lexer.OptionalInclude:
+				kind = Include
+			case lexer.Section:
+				kind = Section
+			}
+			cur = &Node{Kind: kind, Line: t.Pos.Line + line - 1}
+			nodes = append(nodes, cur)
+		}
+		prev = t.Kind
+
+		//A comment after a heredoc marker lies within the heredoc's text
+		if offset(t.Pos) < cursor {
+			continue
+		}
+
+		trivia(offset(t.Pos))
+		cur.Parts = append(cur.Parts, Part{Token: shift(t, line), Raw: string(b[offset(t.Pos):offset(t.End)])})
+		cursor = offset(t.End)
+		if t.End.Line > last {
+			last = t.End.Line
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if cur != nil {
+		trivia(len(b))
+	}
+
+	return nodes, nil
+}
+
+// shift moves the position of a token read from a source starting on the given line of the document.
+func shift(t lexer.Token, line int) lexer.Token {
+	t.Pos.Line += line - 1
+	t.End.Line += line - 1
+	return t
+}
+
+// token returns the first token of the given kinds held by the node.
+func (n *Node) token(kinds ...lexer.Kind) (lexer.Token, bool) {
+	for _, p := range n.Parts {
+		for _, k := range kinds {
+			if !p.Trivia && p.Token.Kind == k {
+				return p.Token, true
+			}
+		}
+	}
+
+	return lexer.Token{}, false
+}
+
+// Key returns the key of an entry, or an empty string for other nodes.
+func (n *Node) Key() string {
+	t, _ := n.token(lexer.Key)
+	return t.Text
+}
+
+// Value returns the value or heredoc token of an entry; its text is the raw value, without quotes.
+func (n *Node) Value() (lexer.Token, bool) {
+	return n.token(lexer.Value, lexer.Heredoc)
+}
+
+// Exported reports whether an entry is preceded by the `export` keyword.
+func (n *Node) Exported() bool {
+	_, ok := n.token(lexer.Export)
+	return ok
+}
+
+// Comment returns the text of the node's comment, including its `#`, or an empty string.
+func (n *Node) Comment() string {
+	t, _ := n.token(lexer.Comment)
+	return t.Text
+}
+
+// String returns the source text of the node, line terminator included.
+func (n *Node) String() string {
+	sb := strings.Builder{}
+	for _, p := range n.Parts {
+		sb.WriteString(p.Raw)
+	}
+
+	return sb.String()
+}
+
+// String prints the document.
+func (d *Document) String() string {
+	sb := strings.Builder{}
+	if d.BOM {
+		sb.Write(bomUTF8)
+	}
+	for _, n := range d.Nodes {
+		sb.WriteString(n.String())
+	}
+
+	return sb.String()
+}
+
+// WriteTo prints the document to the given writer.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, d.String())
+	return int64(n), err
+}
+
+// Lookup returns the entry that defines the given key last, or nil.
+func (d *Document) Lookup(key string) *Node {
+	for i := len(d.Nodes) - 1; i >= 0; i-- {
+		if n := d.Nodes[i]; n.Kind == Entry && n.Key() == key {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// Set replaces the value of the entry that defines the given key last, or appends an entry if the key is not defined.
+// The raw text is written as-is, so it must be quoted and escaped as the document's dialect requires;
+// the edited entry is parsed again, and an error is returned if it does not form a single valid entry.
+func (d *Document) Set(key, raw string) error {
+	n := d.Lookup(key)
+
+	//Build the source text of the edited entry, keeping everything but the value
+	sb := strings.Builder{}
+	line := 1
+	if n != nil {
+		line = n.Line
+		_, bare := n.Value()
+		bare = !bare
+		for _, p := range n.Parts {
+			switch {
+			case p.Trivia:
+				sb.WriteString(p.Raw)
+			case p.Token.Kind == lexer.Value || p.Token.Kind == lexer.Heredoc:
+				sb.WriteString(raw)
+			case p.Token.Kind == lexer.Key && bare:
+				//A key standing alone gains an assignment
+				sb.WriteString(p.Raw + "=" + raw)
+			default:
+				sb.WriteString(p.Raw)
+			}
+		}
+	} else {
+		if len(d.Nodes) > 0 {
+			prev := d.Nodes[len(d.Nodes)-1]
+			line = prev.Line + lineCount(prev.String())
+		}
+		sb.WriteString(key + "=" + raw + d.newline)
+	}
+
+	nodes, err := parse([]byte(sb.String()), d.rules, line)
+	if err != nil {
+		return err
+	}
+	if len(nodes) != 1 || nodes[0].Kind != Entry || nodes[0].Key() != key {
+		return fmt.Errorf("dotenv: value %q does not form a single entry for key `%v`", raw, key)
+	}
+
+	if n != nil {
+		*n = *nodes[0]
+		return nil
+	}
+
+	//Terminate the last line before appending the entry
+	if len(d.Nodes) > 0 {
+		prev := d.Nodes[len(d.Nodes)-1]
+		if s := prev.String(); !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, "\r") {
+			prev.Parts = append(prev.Parts, Part{Trivia: true, Raw: d.newline})
+		}
+	}
+	d.Nodes = append(d.Nodes, nodes[0])
+	return nil
+}
+
+// Delete removes every entry that defines the given key. It returns false if the key is not defined.
+func (d *Document) Delete(key string) bool {
+	kept := d.Nodes[:0]
+	for _, n := range d.Nodes {
+		if n.Kind != Entry || n.Key() != key {
+			kept = append(kept, n)
+		}
+	}
+
+	found := len(kept) != len(d.Nodes)
+	d.Nodes = kept
+	return found
+}
+
+// lineCount returns the number of lines started in the given text; CRLF counts as a single line terminator.
+func lineCount(s string) int {
+	n := strings.Count(s, "\n") + strings.Count(s, "\r") - strings.Count(s, "\r\n")
+	if !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, "\r") {
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,135 @@
+package cst_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golobby/dotenv/v2/pkg/cst"
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+	"github.com/golobby/dotenv/v2/pkg/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_RoundTrip(t *testing.T) {
+	sources := []string{
+		"",
+		"A=1",
+		"A=1\n",
+		"\n\n",
+		"# Header\n\n  export   A = 1   # one\nB=\"x\ny\" # two\n",
+		"\xEF\xBB\xBFA=1\r\nB='2'\r\n\r\n# c\rC=3",
+		"\xEF\xBB\xBF\xEF\xBB\xBFA=1\n",
+		"C=foo \\\n    bar\nD=<<-EOF # doc\n\tbody\n\t  more\n\tEOF\nE=\n",
+		"\tF = \"multi\r\nline\"   \r\n   \r\n",
+		"A=1\n  [ staging :production ]  # inherits\nB=2\n[dev]",
+	}
+	for _, src := range sources {
+		doc, err := cst.Parse(strings.NewReader(src), dialect.Default.Rules())
+		if assert.NoError(t, err, src) {
+			assert.Equal(t, src, doc.String(), src)
+		}
+	}
+
+	src, err := os.ReadFile("./../../assets/.env")
+	assert.NoError(t, err)
+	doc, err := cst.Parse(strings.NewReader(string(src)), dialect.Default.Rules())
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(src), doc.String())
+	}
+
+	//A byte order mark after the first one is kept as trivia, so that tokens still cover their own text
+	doc, err = cst.Parse(strings.NewReader("\xEF\xBB\xBF\xEF\xBB\xBFA=1\n"), dialect.Default.Rules())
+	if assert.NoError(t, err) && assert.Len(t, doc.Nodes, 1) {
+		raw := []string{}
+		for _, p := range doc.Nodes[0].Parts {
+			raw = append(raw, p.Raw)
+		}
+		assert.Equal(t, []string{"\xEF\xBB\xBF", "A", "=", "1", "\n"}, raw)
+		assert.Equal(t, "A", doc.Nodes[0].Key())
+		assert.Equal(t, "\xEF\xBB\xBF\xEF\xBB\xBFA=1\n", doc.String())
+		assert.NoError(t, doc.Set("A", "2"))
+		assert.Equal(t, "\xEF\xBB\xBF\xEF\xBB\xBFA=2\n", doc.String())
+	}
+}
+
+func TestParse_Nodes(t *testing.T) {
+	src := "# Header\n\nexport A = 1 # one\nB=\"x\ny\"\n@include ./shared.env\n[dev] # local\n"
+	doc, err := cst.Parse(strings.NewReader(src), dialect.Default.Rules())
+	assert.NoError(t, err)
+	assert.Equal(t, src, doc.String())
+	if !assert.Len(t, doc.Nodes, 6) {
+		return
+	}
+
+	kinds := []cst.Kind{}
+	lines := []int{}
+	for _, n := range doc.Nodes {
+		kinds = append(kinds, n.Kind)
+		lines = append(lines, n.Line)
+	}
+	assert.Equal(t, []cst.Kind{cst.Comment, cst.Blank, cst.Entry, cst.Entry, cst.Include, cst.Section}, kinds)
+	assert.Equal(t, []int{1, 2, 3, 4, 6, 7}, lines)
+	assert.Equal(t, "# local", doc.Nodes[5].Comment())
+
+	a := doc.Nodes[2]
+	assert.Equal(t, "export A = 1 # one\n", a.String())
+	assert.Equal(t, "A", a.Key())
+	assert.True(t, a.Exported())
+	assert.Equal(t, "# one", a.Comment())
+
+	raws := []string{}
+	for _, p := range a.Parts {
+		raws = append(raws, p.Raw)
+	}
+	assert.Equal(t, []string{"export", " ", "A", " ", "=", " ", "1", " ", "# one", "\n"}, raws)
+
+	v, ok := doc.Lookup("B").Value()
+	assert.True(t, ok)
+	assert.Equal(t, lexer.Value, v.Kind)
+	assert.Equal(t, "x\ny", v.Text)
+	assert.Equal(t, byte('"'), v.Quote)
+	assert.False(t, doc.Lookup("B").Exported())
+	assert.Nil(t, doc.Lookup("C"))
+}
+
+func TestDocument_Edit(t *testing.T) {
+	src := "# Header\r\nexport A = 1   # keep me\r\nB='2'\r\n\r\nA=3"
+	doc, err := cst.Parse(strings.NewReader(src), dialect.Default.Rules())
+	assert.NoError(t, err)
+
+	//Only the value of the last definition changes
+	assert.NoError(t, doc.Set("A", `"hello world"`))
+	assert.Equal(t, "# Header\r\nexport A = 1   # keep me\r\nB='2'\r\n\r\nA=\"hello world\"", doc.String())
+
+	//Unknown keys are appended with the document's line endings
+	assert.NoError(t, doc.Set("C", "4"))
+	assert.Equal(t, "# Header\r\nexport A = 1   # keep me\r\nB='2'\r\n\r\nA=\"hello world\"\r\nC=4\r\n", doc.String())
+	assert.Equal(t, 6, doc.Lookup("C").Line)
+
+	//Values that would not parse back as a single entry are rejected
+	assert.Error(t, doc.Set("B", `"unterminated`))
+	assert.Error(t, doc.Set("B", "x\nD=5"))
+	assert.Equal(t, "B='2'\r\n", doc.Lookup("B").String())
+
+	//Keys standing alone gain a value
+	doc2, err := cst.Parse(strings.NewReader("A # inherited\n"), dialect.Compose.Rules())
+	assert.NoError(t, err)
+	_, ok := doc2.Lookup("A").Value()
+	assert.False(t, ok)
+	assert.NoError(t, doc2.Set("A", "1"))
+	assert.Equal(t, "A=1 # inherited\n", doc2.String())
+
+	assert.True(t, doc.Delete("A"))
+	assert.False(t, doc.Delete("A"))
+	assert.Equal(t, "# Header\r\nB='2'\r\n\r\nC=4\r\n", doc.String())
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := cst.Parse(strings.NewReader("A='x\n"), dialect.Default.Rules())
+	var se *lexer.SyntaxError
+	assert.ErrorAs(t, err, &se)
+
+	_, err = cst.Parse(strings.NewReader("\xFF\xFEA\x00=\x001\x00"), dialect.Default.Rules())
+	assert.EqualError(t, err, "dotenv: only UTF-8 documents can be parsed into a syntax tree")
+}
@@ -0,0 +1,182 @@
+package decoder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Limits that apply to command substitutions when the decoder's options leave them unset.
+const (
+	defaultCommandTimeout   = 10 * time.Second
+	defaultMaxCommandOutput = 1 << 20
+)
+
+// command runs the command substitution at the start of the given string, which begins with `$(`.
+// It returns the command's output, without its trailing newlines, and the number of bytes the substitution spans.
+func (r *_Resolver) command(s string) (string, int, error) {
+	end := closingParen(s)
+	if end < 0 {
+		return "", 0, &_ReferenceError{s, "unterminated command substitution"}
+	}
+	if len(r.opts.Commands) == 0 {
+		return "", 0, fmt.Errorf("dotenv: command substitution `%v` is disabled; list the commands it may run in `Opts.Commands`", s[:end+1])
+	}
+
+	//Each command runs once, however many values substitute it
+	body := s[2:end]
+	if out, ok := r.outputs[body]; ok {
+		return out, end + 1, nil
+	}
+
+	argv, err := words(body)
+	if err != nil {
+		return "", 0, &_ReferenceError{s[:end+1], fmt.Sprintf("%v in command substitution", err)}
+	}
+	if len(argv) == 0 {
+		return "", 0, &_ReferenceError{s[:end+1], "empty command substitution"}
+	}
+	allowed := false
+	for _, c := range r.opts.Commands {
+		allowed = allowed || c == argv[0]
+	}
+	if !allowed {
+		return "", 0, fmt.Errorf("dotenv: command `%v` is not in the allowed commands", argv[0])
+	}
+
+	out, err := run(argv, r.opts.CommandTimeout, r.opts.MaxCommandOutput)
+	if err != nil {
+		return "", 0, fmt.Errorf("dotenv: command `%v` failed; err: %v", body, err)
+	}
+
+	if r.outputs == nil {
+		r.outputs = map[string]string{}
+	}
+	r.outputs[body] = out
+	return out, end + 1, nil
+}
+
+// run executes the given program directly, without a shell, and returns its standard output without trailing newlines.
+// The program is killed if it runs longer than the timeout or writes more than the given number of bytes.
+func run(argv []string, timeout time.Duration, max int64) (string, error) {
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	if max <= 0 {
+		max = defaultMaxCommandOutput
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	//Read in the background, so that a program whose children keep the output open cannot outlive the timeout
+	read := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(io.LimitReader(stdout, max+1))
+		read <- b
+	}()
+
+	var out []byte
+	select {
+	case out = <-read:
+	case <-ctx.Done():
+		//`Wait` may only run once reading is over; closing the pipe ends the read even if children keep it open
+		_ = cmd.Process.Kill()
+		_ = stdout.Close()
+		<-read
+		_ = cmd.Wait()
+		return "", fmt.Errorf("timed out after %v", timeout)
+	}
+	if int64(len(out)) > max {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return "", fmt.Errorf("output exceeds the maximum size of %v bytes", max)
+	}
+
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("timed out after %v", timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// closingParen returns the position of the parenthesis that closes the `$(` at the start of the given string, or -1.
+// Parentheses inside quotes or escaped with a backslash are skipped over.
+func closingParen(s string) int {
+	var quote byte
+	for i := 2; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && quote != '\'':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ')':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// words splits a command into its program and arguments. Words are separated by whitespace and may be quoted;
+// a backslash escapes the next character outside single quotes. Shell syntax is rejected, as no shell runs the command.
+func words(s string) ([]string, error) {
+	argv := []string{}
+	var sb strings.Builder
+	var quote byte
+	word := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && quote != '\'' && i+1 < len(s):
+			sb.WriteByte(s[i+1])
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			sb.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ' ' || c == '\t' || c == '\n':
+			if word {
+				argv = append(argv, sb.String())
+				sb.Reset()
+			}
+			word = false
+			continue
+		case strings.IndexByte("|&;<>()$`*?~", c) >= 0:
+			return nil, fmt.Errorf("shell syntax `%c` is not supported", c)
+		default:
+			sb.WriteByte(c)
+		}
+		word = true
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if word {
+		argv = append(argv, sb.String())
+	}
+
+	return argv, nil
+}
@@ -1,117 +1,362 @@
 package decoder
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/golobby/cast"
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+	"github.com/golobby/dotenv/v2/pkg/lexer"
 )
 
 type Decoder struct {
-	Src io.Reader
+	Src  io.Reader
+	Name string //Name of the data source, e.g. its file path; used in error messages.
+
+	Opts DecoderOpts
+
+	warnings *[]Warning         //Warnings recorded while decoding; nil unless requested through `DecodeWithWarnings`.
+	origins  map[_Place]_Origin //Where the values that may hold references were read from, by entry; filled in by `Parse`.
+	size     *int64             //Bytes read so far from the data source and the files it includes; set by `Parse` if their size is limited.
 }
 
 // Decode reads a dot env (.env) byte slice or file descriptor and fills the given struct fields.
 func (d Decoder) Decode(structure interface{}) error {
-	//Ensure the decoder has a data source to read from
-	if d.Src == nil {
-		return fmt.Errorf("no valid data sources could be found for the decoder")
-	}
-
 	//Read in the dotenv data source
-	kvs, err := d.read(d.Src)
+	entries, err := d.Parse()
 	if err != nil {
 		return err
 	}
 
 	//Populate the struct
-	if err := d.feed(structure, kvs); err != nil {
+	if err := d.feed(structure, d.collect(entries)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// read scans a dot env (.env) data source and extracts its key/value pairs.
-func (d Decoder) read(dat io.Reader) (map[string]string, error) {
-	kvs := map[string]string{}
-	scanner := bufio.NewScanner(dat)
+// DecodeWithWarnings works like `Decode`, and also returns the warnings recorded while reading the data source:
+// lines skipped in lenient mode, duplicate keys and suspicious constructs. Warnings are returned even if decoding fails.
+func (d Decoder) DecodeWithWarnings(structure interface{}) ([]Warning, error) {
+	warnings := []Warning{}
+	d.warnings = &warnings
+	err := d.Decode(structure)
+	return warnings, err
+}
 
-	for i := 1; scanner.Scan(); i++ {
-		if k, v, err := d.parse(scanner.Text()); err != nil {
-			return nil, fmt.Errorf("dotenv: error in line %v; err: %v", i, err)
-		} else if k != "" {
-			kvs[k] = v
-		}
+// warn records a warning about the given line, if warnings were requested.
+func (d Decoder) warn(file string, line int, format string, args ...interface{}) {
+	if d.warnings != nil {
+		*d.warnings = append(*d.warnings, Warning{file, line, fmt.Sprintf(format, args...)})
+	}
+}
+
+// Parse reads a dot env (.env) data source with the default options and returns its entries in file order.
+func Parse(src io.Reader) ([]Entry, error) {
+	return Decoder{Src: src, Opts: DefaultOpts()}.Parse()
+}
+
+// Parse reads the decoder's data source and returns its entries in file order, with their values decoded.
+// Every definition of a key is listed; `Decode` applies the duplicate policy when filling struct fields.
+// In files with sections, the entries outside any section come first, followed by those of the selected section's
+// ancestors and of the section itself; keys defined by a later section replace those listed before it.
+func (d Decoder) Parse() ([]Entry, error) {
+	//Ensure the decoder has a data source to read from
+	if d.Src == nil {
+		return nil, fmt.Errorf("no valid data sources could be found for the decoder")
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("dotenv: error when scanning file; err: %v", err)
+	//Included files are resolved relative to the data source, which starts the chain of includes
+	stack := []string{}
+	if d.Name != "" {
+		stack = append(stack, d.Name)
 	}
 
-	return kvs, nil
+	sections := map[string]string{}
+	d.origins = map[_Place]_Origin{}
+	if d.Opts.MaxBytes > 0 {
+		d.size = new(int64)
+	}
+	entries, err := d.read(d.Src, []Entry{}, stack, sections)
+	if err != nil {
+		return nil, err
+	}
+	if entries, err = d.section(entries, sections); err != nil {
+		return nil, err
+	}
+
+	//Expand escape sequences and variable references
+	if err := d.resolve(entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
 }
 
-// parse extracts a key/value pair from the given dot env (.env) single line.
-func (d Decoder) parse(line string) (string, string, error) {
-	ln := strings.TrimSpace(line)
-	kv := []string{"", ""}
-	pi := 0
-	iq := false
-	qt := "'"
+// collect gathers the given entries by key, following the decoder's duplicate policy.
+func (d Decoder) collect(entries []Entry) map[string][]Entry {
+	//Single entries are subslices of the given ones rather than a slice allocated per key
+	kvs := make(map[string][]Entry, len(entries))
+	for i, e := range entries {
+		//Unset entries and, if requested, unquoted empty values leave fields alone
+		if e.Unset || (d.Opts.SkipEmpty && !e.Bare && e.Raw == "" && e.Quote == 0) {
+			continue
+		}
 
-	for i := 0; i < len(ln); i++ {
-		if string(ln[i]) == "#" && pi == 0 {
-			break
+		vals, dup := kvs[e.Key]
+		switch {
+		case dup && d.Opts.Duplicates == DuplicateFirstWins:
+			continue
+		case dup && d.Opts.Duplicates == DuplicateCollect:
+			kvs[e.Key] = append(vals, e)
+		default:
+			kvs[e.Key] = entries[i : i+1 : i+1]
 		}
+	}
+
+	return kvs
+}
+
+// read scans a dot env (.env) data source and appends its raw key/value pairs to the given ones, in file order.
+// Included files are read in place of their directive; the stack lists the files that are being read.
+// Section headers are recorded in the given map, along with the section each one inherits from.
+func (d Decoder) read(dat io.Reader, lines []Entry, stack []string, sections map[string]string) ([]Entry, error) {
+	//Included files draw on the same size limit as the data source
+	var m *_Metered
+	if d.size != nil {
+		m = &_Metered{r: dat, max: d.Opts.MaxBytes, used: d.size}
+		dat = m
+	}
+
+	s := lexer.NewScanner(dat, d.rules())
+	s.Name = d.Name
+	s.MaxLineLength = d.Opts.MaxLineLength
+	if d.Opts.StrictKeys {
+		s.KeyPattern = d.Opts.keyPattern()
+	}
+	s.Includes = d.Opts.Includes
+	s.Sections = true
+	s.Lenient = d.Opts.Lenient
+	s.OmitCommentText = true
 
-		if string(ln[i]) == "#" && pi == 1 && !iq {
-			break
+	//Lines skipped in lenient mode are reported as the scanner passes them
+	skipped := 0
+	report := func() {
+		for ; skipped < len(s.Skipped()); skipped++ {
+			se := s.Skipped()[skipped]
+			d.warn(d.Name, se.Line, "skipped malformed line; %v at column %v", se.Reason, se.Column)
 		}
+	}
 
-		if string(ln[i]) == "=" && pi == 0 {
-			pi = 1
-			continue
+	//Each entry is a key token, followed by an assignment and a value unless the key stands alone
+	//A comment on the entry's last line belongs to it
+	//Entries belong to the section of the last header above them; included files start in the section of their directive
+	var prev lexer.Token
+	section := ""
+	for s.Scan() {
+		if err := d.checkSize(m); err != nil {
+			return nil, err
 		}
+		report()
 
-		if string(ln[i]) == " " && pi == 1 {
-			if !iq && kv[pi] == "" {
-				continue
+		switch t := s.Token(); t.Kind {
+		case lexer.Key:
+			lines = append(lines, Entry{Key: t.Text, Section: section, File: d.Name, Bare: true, Line: t.Pos.Line})
+			if err := d.checkKeys(len(lines)); err != nil {
+				return nil, err
 			}
-		}
+		case lexer.Section:
+			section = t.Text
+			if _, ok := sections[section]; !ok {
+				sections[section] = ""
+			}
+		case lexer.Extends:
+			sections[section] = t.Text
+		case lexer.Include, lexer.OptionalInclude:
+			n := len(lines)
+			var err error
+			if lines, err = d.include(t, lines, stack, sections); err != nil {
+				return nil, err
+			}
+			for i := n; i < len(lines); i++ {
+				if lines[i].Section == "" {
+					lines[i].Section = section
+				}
+			}
+		case lexer.Value, lexer.Heredoc:
+			e := &lines[len(lines)-1]
+			e.Raw, e.Quote, e.Bare = t.Text, t.Quote, false
 
-		if (string(ln[i]) == "\"" || string(ln[i]) == "'") && pi == 1 {
-			if kv[pi] == "" {
-				iq = true
-				qt = string(ln[i])
-				continue
-			} else if iq && qt == string(ln[i]) {
-				break
+			//Malformed references are reported against the source, so remember where they may come from
+			if strings.IndexByte(t.Text, '$') >= 0 {
+				d.origins[_Place{e.File, e.Line}] = _Origin{t, s.Lines(t)}
 			}
+		case lexer.Comment:
+			if (prev.Kind == lexer.Key || prev.Kind == lexer.Value || prev.Kind == lexer.Heredoc) && t.Pos.Line <= prev.End.Line {
+				lines[len(lines)-1].Comment = t.Text
+			}
+
+			//A `#` stuck to an unquoted value cuts it short, which is rarely intended
+			if prev.Kind == lexer.Value && prev.Quote == 0 && prev.End.Offset == t.Pos.Offset {
+				d.warn(d.Name, t.Pos.Line, "`#` right after the value of `%v` starts a comment; quote the value to keep it", lines[len(lines)-1].Key)
+			}
+		}
+		prev = s.Token()
+	}
+	if err := d.checkSize(m); err != nil {
+		return nil, err
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	report()
+
+	return lines, nil
+}
+
+// keyPattern returns the pattern keys must match in strict mode.
+func (o DecoderOpts) keyPattern() *regexp.Regexp {
+	if o.KeyPattern != nil {
+		return o.KeyPattern
+	}
+
+	return dialect.Identifier
+}
+
+// rules returns the syntax rules to parse with; the decoder options fine-tune the default dialect.
+func (d Decoder) rules() dialect.Rules {
+	r := d.Opts.Dialect.Rules()
+	if d.Opts.Dialect == dialect.Default {
+		r.Interpolate = d.Opts.Interpolate
+		r.InlineComments = d.Opts.InlineComments
+		r.BareKeys = d.Opts.BareKeys
+	}
+
+	return r
+}
+
+// checkKeys ensures the number of entries read so far does not exceed the configured limit.
+func (d Decoder) checkKeys(n int) error {
+	if d.Opts.MaxKeys > 0 && n > d.Opts.MaxKeys {
+		return fmt.Errorf("dotenv: file exceeds the maximum of %v entries", d.Opts.MaxKeys)
+	}
+
+	return nil
+}
+
+// checkSize ensures the bytes read so far, from the data source and the files it includes, do not exceed the configured limit.
+// The file being read, whose bytes are counted by the given reader, is named in the error.
+func (d Decoder) checkSize(m *_Metered) error {
+	if m == nil || *m.used <= m.max {
+		return nil
+	}
+
+	switch {
+	case m.n < *m.used:
+		return fmt.Errorf("dotenv: %v exceeds the maximum size of %v bytes, counting the files that include it", d.Name, d.Opts.MaxBytes)
+	case d.Name != "":
+		return fmt.Errorf("dotenv: %v exceeds the maximum size of %v bytes", d.Name, d.Opts.MaxBytes)
+	}
+
+	return fmt.Errorf("dotenv: file exceeds the maximum size of %v bytes", d.Opts.MaxBytes)
+}
+
+// Reads a data source while counting its bytes, as stored, towards a limit shared with the files it includes.
+// Reading stops one byte past the limit, which is enough to tell that it was exceeded.
+type _Metered struct {
+	r    io.Reader
+	max  int64
+	used *int64 //Bytes read from every file so far.
+	n    int64  //Bytes read from this file.
+}
+
+func (m *_Metered) Read(p []byte) (int, error) {
+	left := m.max + 1 - *m.used
+	if left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > left {
+		p = p[:left]
+	}
+
+	n, err := m.r.Read(p)
+	*m.used += int64(n)
+	m.n += int64(n)
+	return n, err
+}
+
+// unescape decodes the escape sequence that follows a backslash in a quoted value, given the characters that may be escaped.
+// It returns the decoded text and the number of bytes consumed after the backslash.
+func unescape(seq string, allowed string) (string, int) {
+	//Unknown escapes are kept as-is, backslash included
+	if strings.IndexByte(allowed, seq[0]) < 0 {
+		return "\\" + seq[:1], 1
+	}
+
+	switch seq[0] {
+	case 'n':
+		return "\n", 1
+	case 't':
+		return "\t", 1
+	case 'r':
+		return "\r", 1
+	case 'a':
+		return "\a", 1
+	case 'b':
+		return "\b", 1
+	case 'f':
+		return "\f", 1
+	case 'v':
+		return "\v", 1
+	case '\n':
+		//An escaped line break joins the lines
+		return "", 1
+	case 'u':
+		r, ok := hex4(seq[1:])
+		if !ok {
+			return "\\u", 1
 		}
 
-		kv[pi] += string(ln[i])
+		//Combine UTF-16 surrogate pairs written as two consecutive `\uXXXX` escapes
+		if utf16.IsSurrogate(r) && len(seq) >= 11 && seq[5:7] == "\\u" {
+			if r2, ok := hex4(seq[7:]); ok {
+				if pair := utf16.DecodeRune(r, r2); pair != utf8.RuneError {
+					return string(pair), 11
+				}
+			}
+		}
+		return string(r), 5
 	}
 
-	kv[0] = strings.TrimSpace(kv[0])
-	if !iq {
-		kv[1] = strings.TrimSpace(kv[1])
+	//Punctuation stands for itself
+	return seq[:1], 1
+}
+
+// hex4 parses the four hexadecimal digits at the start of the given string as a rune.
+func hex4(s string) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
 	}
 
-	if (pi == 0 && kv[0] != "") || (pi == 1 && kv[0] == "") {
-		return "", "", fmt.Errorf("dotenv: invalid syntax")
+	n, err := strconv.ParseUint(s[:4], 16, 16)
+	if err != nil {
+		return 0, false
 	}
 
-	return kv[0], kv[1], nil
+	return rune(n), true
 }
 
-// feed sets struct fields with the given key/value pairs.
-func (d Decoder) feed(structure interface{}, kvs map[string]string) error {
+// feed sets struct fields with the given entries, gathered by key.
+func (d Decoder) feed(structure interface{}, kvs map[string][]Entry) error {
 	inputType := reflect.TypeOf(structure)
 	if inputType != nil {
 		if inputType.Kind() == reflect.Ptr {
@@ -124,8 +369,8 @@ func (d Decoder) feed(structure interface{}, kvs map[string]string) error {
 	return errors.New("dotenv decode: invalid structure")
 }
 
-// feedStruct sets reflected struct fields with the given key/value pairs.
-func (d Decoder) feedStruct(s reflect.Value, vars map[string]string) error {
+// feedStruct sets reflected struct fields with the given entries, gathered by key.
+func (d Decoder) feedStruct(s reflect.Value, vars map[string][]Entry) error {
 	//Iterate over the fields of the struct
 	for i := 0; i < s.NumField(); i++ {
 		//Get the current field info
@@ -135,16 +380,13 @@ func (d Decoder) feedStruct(s reflect.Value, vars map[string]string) error {
 		//Check for the `env` struct tag
 		if t, exist := field.Tag.Lookup("env"); exist {
 			//Case 1: ordinary field; parse the string and populate the corresponding struct field
-			if val, exist := vars[t]; exist {
-				//Perform the cast to the same type as the target field
-				v, err := cast.FromType(val, field.Type)
-				if err != nil {
+			key, layout := dialect.SplitTag(t)
+			if entries, exist := vars[key]; exist {
+				//Set the value using `unsafe`, so that unexported fields are populated too
+				ptr := reflect.NewAt(fieldValue.Type(), unsafe.Pointer(fieldValue.UnsafeAddr())).Elem()
+				if err := setValue(ptr, entries, layout); err != nil {
 					return fmt.Errorf("dotenv: cannot set `%v` field; err: %v", field.Name, err)
 				}
-
-				//Set the value using `unsafe`
-				ptr := reflect.NewAt(fieldValue.Type(), unsafe.Pointer(fieldValue.UnsafeAddr())).Elem()
-				ptr.Set(reflect.ValueOf(v))
 			}
 		} else if field.Type.Kind() == reflect.Struct {
 			//Case 2: field is an embedded struct; recursively process it
@@ -163,3 +405,85 @@ func (d Decoder) feedStruct(s reflect.Value, vars map[string]string) error {
 
 	return nil
 }
+
+// setValue decodes the entries of a key into the given settable value. Keys only have several entries if duplicates are collected;
+// slices then receive the elements of every occurrence in file order, and other values receive the last one.
+// Standard library types that casting does not handle are decoded by `decodeStd`, and types implementing `Unmarshaler` or
+// `encoding.TextUnmarshaler` decode themselves, as do the elements of slices; others are cast. Pointers are left alone if decoding fails.
+func setValue(v reflect.Value, entries []Entry, layout string) error {
+	last := entries[len(entries)-1]
+	if ok, err := decodeValue(v, last, last.Value, layout); ok {
+		return err
+	}
+
+	//Pointers to other types mark optional values; they are only allocated once their key is found and its value decodes
+	if v.Kind() == reflect.Ptr {
+		tmp := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			tmp.Elem().Set(v.Elem())
+		}
+		if err := setValue(tmp.Elem(), entries, layout); err != nil {
+			return err
+		}
+
+		//Pointers that are already set keep pointing to the same value
+		if v.IsNil() {
+			v.Set(tmp)
+		} else {
+			v.Elem().Set(tmp.Elem())
+		}
+		return nil
+	}
+
+	if v.Kind() != reflect.Slice || (!isStd(v.Type().Elem()) && !unmarshals(v.Type().Elem())) {
+		c, err := castValues(entries, v.Type())
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(c))
+		return nil
+	}
+
+	//Array elements are separated by commas, as they are when casting
+	all := reflect.MakeSlice(v.Type(), 0, len(entries))
+	for _, e := range entries {
+		for _, item := range strings.Split(e.Value, ",") {
+			el := reflect.New(v.Type().Elem()).Elem()
+			if _, err := decodeValue(el, e, strings.Trim(item, " \n\r"), layout); err != nil {
+				return err
+			}
+			all = reflect.Append(all, el)
+		}
+	}
+	v.Set(all)
+	return nil
+}
+
+// decodeValue decodes a value of an entry into a settable value of a standard library type or of a type that decodes itself.
+// It returns false for other types.
+func decodeValue(v reflect.Value, e Entry, value, layout string) (bool, error) {
+	if ok, err := decodeStd(v, value, layout); ok {
+		return true, err
+	}
+
+	return unmarshal(v, e, value)
+}
+
+// castValues casts the values of a key's entries to the given type. Keys only have several entries if duplicates are collected;
+// slice fields then receive the elements of every occurrence in file order, and other fields receive the last value.
+func castValues(entries []Entry, typ reflect.Type) (interface{}, error) {
+	if len(entries) == 1 || typ.Kind() != reflect.Slice {
+		return cast.FromType(entries[len(entries)-1].Value, typ)
+	}
+
+	all := reflect.MakeSlice(typ, 0, len(entries))
+	for _, e := range entries {
+		v, err := cast.FromType(e.Value, typ)
+		if err != nil {
+			return nil, err
+		}
+		all = reflect.AppendSlice(all, reflect.ValueOf(v))
+	}
+
+	return all.Interface(), nil
+}
@@ -1,11 +1,19 @@
 package decoder_test
 
 import (
+	"fmt"
+	"math/big"
+	"net"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/golobby/dotenv/v2"
 	"github.com/golobby/dotenv/v2/pkg/decoder"
+	"github.com/golobby/dotenv/v2/pkg/dialect"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -183,3 +191,1024 @@ func TestLoad_With_Invalid_Nested_Structure_Ptr_Field_It_Should_Fail(t *testing.
 	err = f.Close()
 	assert.NoError(t, err)
 }
+
+func TestLoad_Multiline_Quoted_Value(t *testing.T) {
+	src := "BEFORE=1\nCERT=\"-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\"\nSINGLE='a\n b'\nAFTER=2\n"
+
+	c := &struct {
+		Before int    `env:"BEFORE"`
+		Cert   string `env:"CERT"`
+		Single string `env:"SINGLE"`
+		After  int    `env:"AFTER"`
+	}{}
+	err := dotenv.NewDecoder([]byte(src)).Decode(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, c.Before)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----", c.Cert)
+	assert.Equal(t, "a\n b", c.Single)
+	assert.Equal(t, 2, c.After)
+}
+
+func TestLoad_With_Unterminated_Quote_It_Should_Fail(t *testing.T) {
+	src := "A=1\nB=\"open\nstill open\n"
+
+	c := &struct {
+		A int `env:"A"`
+	}{}
+	err := dotenv.NewDecoder([]byte(src)).Decode(c)
+	assert.EqualError(t, err, "dotenv: syntax error at line 2, column 3: unterminated quote\n 2 | B=\"open\n   |   ^")
+}
+
+func TestLoad_Escape_Sequences(t *testing.T) {
+	src := `DOUBLE="a\nb\tc\rd \"e\" \\f \$g é 😀 \q"` + "\n" + `SINGLE='a\nb \"c\"'` + "\n" + `BARE=a\nb` + "\n"
+
+	c := &struct {
+		Double string `env:"DOUBLE"`
+		Single string `env:"SINGLE"`
+		Bare   string `env:"BARE"`
+	}{}
+	err := dotenv.NewDecoder([]byte(src)).Decode(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "a\nb\tc\rd \"e\" \\f $g é 😀 \\q", c.Double)
+	assert.Equal(t, `a\nb \"c\"`, c.Single)
+	assert.Equal(t, `a\nb`, c.Bare)
+}
+
+func TestLoad_Interpolation(t *testing.T) {
+	t.Setenv("DOTENV_TEST_HOST", "env-host")
+	t.Setenv("DOTENV_TEST_EMPTY", "")
+
+	src := `
+DB_HOST=db.local
+DB_PORT=5432
+DB_URL=postgres://${DB_HOST}:$DB_PORT/app
+FROM_ENV=$DOTENV_TEST_HOST
+SELF=$DOTENV_TEST_HOST/sub
+DOTENV_TEST_HOST=${DOTENV_TEST_HOST}:extra
+AFTER=$DOTENV_TEST_HOST
+FORWARD=${LATER:-unset}
+LATER=1
+DEFAULT1=${DOTENV_TEST_EMPTY:-fallback}
+DEFAULT2=${DOTENV_TEST_EMPTY-fallback}
+DEFAULT3="${DOTENV_TEST_UNSET:-${DB_HOST:-x}}"
+ALT1=${DB_HOST:+alt}
+ALT2=${DOTENV_TEST_UNSET:+alt}
+SINGLE='${DB_HOST}'
+ESCAPED="\${DB_HOST} costs \$5"
+`
+	c := &struct {
+		DBURL    string `env:"DB_URL"`
+		FromEnv  string `env:"FROM_ENV"`
+		Self     string `env:"SELF"`
+		Host     string `env:"DOTENV_TEST_HOST"`
+		After    string `env:"AFTER"`
+		Forward  string `env:"FORWARD"`
+		Default1 string `env:"DEFAULT1"`
+		Default2 string `env:"DEFAULT2"`
+		Default3 string `env:"DEFAULT3"`
+		Alt1     string `env:"ALT1"`
+		Alt2     string `env:"ALT2"`
+		Single   string `env:"SINGLE"`
+		Escaped  string `env:"ESCAPED"`
+	}{}
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Interpolate = true
+	dec.Opts.UseEnv = true
+	assert.NoError(t, dec.Decode(c))
+
+	//References see the keys defined above them, then the environment; keys defined further down are not visible
+	assert.Equal(t, "postgres://db.local:5432/app", c.DBURL)
+	assert.Equal(t, "env-host", c.FromEnv)
+	assert.Equal(t, "env-host/sub", c.Self)
+	assert.Equal(t, "env-host:extra", c.Host)
+	assert.Equal(t, "env-host:extra", c.After)
+	assert.Equal(t, "unset", c.Forward)
+	assert.Equal(t, "fallback", c.Default1)
+	assert.Equal(t, "", c.Default2)
+	assert.Equal(t, "db.local", c.Default3)
+	assert.Equal(t, "alt", c.Alt1)
+	assert.Equal(t, "", c.Alt2)
+	assert.Equal(t, "${DB_HOST}", c.Single)
+	assert.Equal(t, "${DB_HOST} costs $5", c.Escaped)
+}
+
+func TestLoad_Interpolation_Disabled(t *testing.T) {
+	c := &struct {
+		A string `env:"A"`
+	}{}
+	err := decoder.Decoder{Src: strings.NewReader("B=1\nA=${B}\n")}.Decode(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "${B}", c.A)
+
+	err = dotenv.NewDecoder([]byte("B=1\nA=pa$$word${B}\n")).Decode(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "pa$$word${B}", c.A)
+}
+
+func TestLoad_Interpolation_Errors(t *testing.T) {
+	dec := dotenv.NewDecoder([]byte("A=1\nB=${DOTENV_TEST_UNSET:?is required}\n"))
+	dec.Opts.Interpolate = true
+	assert.EqualError(t, dec.Decode(&struct{}{}), "dotenv: error in line 2; err: dotenv: `DOTENV_TEST_UNSET`: is required")
+
+	//Malformed references are syntax errors pointing at their `$`
+	tests := []struct {
+		src    string
+		line   int
+		column int
+		text   string
+		reason string
+	}{
+		{"A=${B\n", 1, 3, "A=${B", "unterminated variable reference `${B`"},
+		{"A=${B:=x}\n", 1, 3, "A=${B:=x}", "bad substitution `${B:=x}`"},
+		{"A=\"${B:-${1}}\"\n", 1, 9, "A=\"${B:-${1}}\"", "bad substitution `${1}`"},
+		{"A=1\nB=\"x\n  ${:-y}\"\n", 3, 3, "  ${:-y}\"", "bad substitution `${:-y}`"},
+		{"A=<<-EOF\n    ${B\n    EOF\n", 2, 5, "    ${B", "unterminated variable reference `${B`"},
+		{"A=$(echo\n", 1, 3, "A=$(echo", "unterminated command substitution `$(echo`"},
+	}
+
+	for _, test := range tests {
+		var se *decoder.SyntaxError
+		dec := dotenv.NewDecoder([]byte(test.src))
+		dec.Name = "test.env"
+		dec.Opts.Interpolate = true
+		err := dec.Decode(&struct{}{})
+		if assert.ErrorAs(t, err, &se, test.src) {
+			assert.Equal(t, decoder.SyntaxError{File: "test.env", Line: test.line, Column: test.column, Text: test.text, Reason: test.reason}, *se, test.src)
+		}
+	}
+}
+
+func TestLoad_Export_Keyword(t *testing.T) {
+	src := "export DB_HOST=localhost\nexport\tDB_PORT = 5432\nexport=kept\n"
+
+	c := &struct {
+		Host   string `env:"DB_HOST"`
+		Port   int    `env:"DB_PORT"`
+		Export string `env:"export"`
+	}{}
+	err := dotenv.NewDecoder([]byte(src)).Decode(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "localhost", c.Host)
+	assert.Equal(t, 5432, c.Port)
+	assert.Equal(t, "kept", c.Export)
+}
+
+func TestLoad_Line_Continuation(t *testing.T) {
+	src := "JAVA_OPTS=-Xms256m \\\n    -Xmx1g \\\n    -XX:+UseG1GC\nFLAGS = a,b,\\\n  c,d # comment \\\nNOT_CONTINUED=x # \\\nQUOTED=\"a\\\\\"\nLAST=end\\"
+
+	c := &struct {
+		JavaOpts     string   `env:"JAVA_OPTS"`
+		Flags        []string `env:"FLAGS"`
+		NotContinued string   `env:"NOT_CONTINUED"`
+		Quoted       string   `env:"QUOTED"`
+		Last         string   `env:"LAST"`
+	}{}
+	err := dotenv.NewDecoder([]byte(src)).Decode(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "-Xms256m -Xmx1g -XX:+UseG1GC", c.JavaOpts)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, c.Flags)
+	assert.Equal(t, "x", c.NotContinued)
+	assert.Equal(t, "a\\", c.Quoted)
+	assert.Equal(t, "end", c.Last)
+}
+
+func TestLoad_With_Invalid_Multiline_Entry_It_Should_Fail(t *testing.T) {
+	dec := dotenv.NewDecoder([]byte("A=1\nB=\"multi\nline\" trailing\n"))
+	dec.Opts.Dialect = dialect.POSIX
+
+	err := dec.Decode(&struct{}{})
+	assert.EqualError(t, err, "dotenv: syntax error at line 3, column 7: unexpected text after the value\n 3 | line\" trailing\n   |       ^")
+}
+
+func TestLoad_Heredoc(t *testing.T) {
+	src := `NAME=app
+QUERY=<<SQL
+SELECT *
+  FROM ${NAME}
+SQL
+JSON=<<-EOF # indented
+    {
+      "name": "$NAME"
+    }
+    EOF
+RAW=<<'EOF'
+${NAME}\n
+EOF
+AFTER=1
+`
+	c := &struct {
+		Query string `env:"QUERY"`
+		JSON  string `env:"JSON"`
+		Raw   string `env:"RAW"`
+		After int    `env:"AFTER"`
+	}{}
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Interpolate = true
+	err := dec.Decode(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "SELECT *\n  FROM app", c.Query)
+	assert.Equal(t, "{\n  \"name\": \"app\"\n}", c.JSON)
+	assert.Equal(t, "${NAME}\\n", c.Raw)
+	assert.Equal(t, 1, c.After)
+}
+
+func TestLoad_With_Unterminated_Heredoc_It_Should_Fail(t *testing.T) {
+	c := &struct{}{}
+	err := dotenv.NewDecoder([]byte("A=1\nB=<<EOF\nbody\n")).Decode(c)
+	assert.EqualError(t, err, "dotenv: syntax error at line 2, column 3: unterminated heredoc; missing `EOF`\n 2 | B=<<EOF\n   |   ^")
+}
+
+func TestLoad_Encodings(t *testing.T) {
+	type Config struct {
+		AppName string `env:"APP_NAME"`
+		Multi   string `env:"MULTI"`
+		Port    int    `env:"PORT"`
+	}
+	expected := Config{"DotEnv", "a\nb", 80}
+	text := "APP_NAME=DotEnv\r\nMULTI=\"a\r\nb\"\rPORT=80\r\n"
+
+	sources := map[string][]byte{
+		"utf-8 bom":            append([]byte{0xEF, 0xBB, 0xBF}, text...),
+		"utf-16le":             append([]byte{0xFF, 0xFE}, utf16Bytes(text, false)...),
+		"utf-16be":             append([]byte{0xFE, 0xFF}, utf16Bytes(text, true)...),
+		"utf-16le without bom": utf16Bytes(text, false),
+	}
+	for name, src := range sources {
+		c := Config{}
+		err := dotenv.NewDecoder(src).Decode(&c)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, c, name)
+	}
+
+	err := dotenv.NewDecoder([]byte{0xFF, 0xFE, 0x00, 0x00, 'A', 0, 0, 0}).Decode(&Config{})
+	assert.EqualError(t, err, "dotenv: error when reading file; err: dotenv: UTF-32 encoded files are not supported; please convert the file to UTF-8")
+}
+
+// utf16Bytes encodes an ASCII string as UTF-16 without a BOM.
+func utf16Bytes(s string, bigEndian bool) []byte {
+	b := make([]byte, 0, 2*len(s))
+	for i := 0; i < len(s); i++ {
+		if bigEndian {
+			b = append(b, 0, s[i])
+		} else {
+			b = append(b, s[i], 0)
+		}
+	}
+
+	return b
+}
+
+func TestLoad_Long_Lines(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+
+	c := &struct {
+		Long string `env:"LONG"`
+		Next int    `env:"NEXT"`
+	}{}
+	err := dotenv.NewDecoder([]byte("LONG=" + long + "\nNEXT=1\n")).Decode(c)
+	assert.NoError(t, err)
+
+	assert.Equal(t, long, c.Long)
+	assert.Equal(t, 1, c.Next)
+}
+
+func TestLoad_With_Exceeded_Limits_It_Should_Fail(t *testing.T) {
+	src := "C=1\nB=\"${UNSET:-${UNSET2:-$C}}\"\nA=${B}\nLONG=" + strings.Repeat("x", 100) + "\n"
+
+	tests := []struct {
+		opts func(o *decoder.DecoderOpts)
+		msg  string
+	}{
+		{func(o *decoder.DecoderOpts) { o.MaxBytes = 64 }, "dotenv: file exceeds the maximum size of 64 bytes"},
+		{func(o *decoder.DecoderOpts) { o.MaxLineLength = 50 }, "dotenv: line 4 exceeds the maximum length of 50 bytes"},
+		{func(o *decoder.DecoderOpts) { o.MaxKeys = 3 }, "dotenv: file exceeds the maximum of 3 entries"},
+		{func(o *decoder.DecoderOpts) { o.MaxInterpolationDepth = 2 }, "dotenv: error in line 2; err: dotenv: interpolation exceeds the maximum depth of 2"},
+	}
+	for _, test := range tests {
+		dec := dotenv.NewDecoder([]byte(src))
+		dec.Opts.Interpolate = true
+		test.opts(&dec.Opts)
+		err := dec.Decode(&struct{}{})
+		assert.EqualError(t, err, test.msg)
+	}
+
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Interpolate = true
+	dec.Opts.MaxBytes = int64(len(src))
+	dec.Opts.MaxLineLength = 105
+	dec.Opts.MaxKeys = 4
+	dec.Opts.MaxInterpolationDepth = 4
+	assert.NoError(t, dec.Decode(&struct{}{}))
+
+	//The size is measured before CRLF line endings are converted
+	dec = dotenv.NewDecoder([]byte("A=1\r\nB=123456789\r\n"))
+	dec.Opts.MaxBytes = 14
+	assert.EqualError(t, dec.Decode(&struct{}{}), "dotenv: file exceeds the maximum size of 14 bytes")
+}
+
+func TestLoad_Dialects(t *testing.T) {
+	type Config struct {
+		A string `env:"A"`
+		B string `env:"B"`
+		C string `env:"C"`
+		D string `env:"D"`
+	}
+
+	tests := []struct {
+		dialect  dialect.Dialect
+		src      string
+		expected Config
+	}{
+		{dialect.Docker, "A=\"quoted\" # kept\nB= spaced \n  C=$A\n# D=comment\n", Config{"\"quoted\" # kept", " spaced ", "$A", ""}},
+		{dialect.Systemd, "; comment\nA=\"x\\ny \\\"q\\\"\"\nB=a\\ b # kept\nC='$A\\n'\nD=\"multi\\\nline\"\n", Config{"x\\ny \"q\"", "a b # kept", "$A\\n", "multiline"}},
+		{dialect.Compose, "export A=x#1 # comment\nB=\"${A}\\t$A\"\nC='${A}'\nD=${UNSET:-#}\n", Config{"x#1", "x#1\tx#1", "${A}", "#"}},
+		{dialect.POSIX, "A=a\\ b\nB=\"$A \\n\\$\"\nC='\\n' # comment\nD=#x\n", Config{"a b", "a b \\n$", "\\n", "#x"}},
+		{dialect.Node, "A=x # comment\nB=\"a\\nb \\t\"\nC=`it's \"q\"`\nD=$A\n", Config{"x", "a\nb \\t", "it's \"q\"", "$A"}},
+		{dialect.Python, "A=x\nB=\"${A} $A\\t\"\nC='it\\'s \\n'\nD=a#b #c\n", Config{"x", "x $A\t", "it's \\n", "a#b"}},
+	}
+
+	for _, test := range tests {
+		dec := dotenv.NewDecoder([]byte(test.src))
+		dec.Opts.Dialect = test.dialect
+
+		c := Config{}
+		err := dec.Decode(&c)
+		assert.NoError(t, err, test.dialect.String())
+		assert.Equal(t, test.expected, c, test.dialect.String())
+	}
+}
+
+func TestLoad_With_Invalid_Dialect_Syntax_It_Should_Fail(t *testing.T) {
+	tests := []struct {
+		dialect dialect.Dialect
+		src     string
+		line    int
+		column  int
+		reason  string
+	}{
+		{dialect.Docker, "A B=1\n", 1, 2, "whitespace in key"},
+		{dialect.POSIX, "A = 1\n", 1, 2, "whitespace in key"},
+		{dialect.POSIX, "A=1 2\n", 1, 5, "unexpected text after the value"},
+		{dialect.POSIX, "A= 1\n", 1, 4, "unexpected text after the value"},
+	}
+
+	for _, test := range tests {
+		dec := dotenv.NewDecoder([]byte(test.src))
+		dec.Opts.Dialect = test.dialect
+
+		var se *decoder.SyntaxError
+		err := dec.Decode(&struct{}{})
+		if assert.ErrorAs(t, err, &se, test.dialect.String()) {
+			assert.Equal(t, test.line, se.Line, test.src)
+			assert.Equal(t, test.column, se.Column, test.src)
+			assert.Equal(t, test.reason, se.Reason, test.src)
+		}
+	}
+}
+
+func TestLoad_Duplicate_Keys(t *testing.T) {
+	src := "HOST=a\nPORT=1\nHOST=b\nHOSTS=x,y\nPORT=2\nHOSTS=z\n"
+	type Config struct {
+		Host  string   `env:"HOST"`
+		Port  int      `env:"PORT"`
+		Hosts []string `env:"HOSTS"`
+	}
+
+	tests := map[decoder.DuplicatePolicy]Config{
+		decoder.DuplicateLastWins:  {"b", 2, []string{"z"}},
+		decoder.DuplicateFirstWins: {"a", 1, []string{"x", "y"}},
+		decoder.DuplicateCollect:   {"b", 2, []string{"x", "y", "z"}},
+	}
+	for policy, expected := range tests {
+		dec := dotenv.NewDecoder([]byte(src))
+		dec.Opts.Duplicates = policy
+
+		c := Config{}
+		err := dec.Decode(&c)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, c)
+	}
+
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Duplicates = decoder.DuplicateError
+	err := dec.Decode(&Config{})
+	assert.EqualError(t, err, "dotenv: duplicate key `HOST` in line 1 and line 3")
+}
+
+func TestLoad_Syntax_Errors(t *testing.T) {
+	tests := []struct {
+		src    string
+		line   int
+		column int
+		text   string
+		reason string
+	}{
+		{"A=1\n  =2\n", 2, 3, "  =2", "missing key"},
+		{"A=1\nexport  KEY\n", 2, 12, "export  KEY", "missing `=` after the key"},
+		{"A #comment=1\n", 1, 3, "A #comment=1", "missing `=` after the key"},
+		{"A=1\nB=\\\n  \"x\nC=2\n", 3, 3, "  \"x", "unterminated quote"},
+		{"\tA='x\n", 1, 4, "\tA='x", "unterminated quote"},
+	}
+
+	for _, test := range tests {
+		var se *decoder.SyntaxError
+		err := dotenv.NewDecoder([]byte(test.src)).Decode(&struct{}{})
+		if assert.ErrorAs(t, err, &se, test.src) {
+			assert.Equal(t, decoder.SyntaxError{Line: test.line, Column: test.column, Text: test.text, Reason: test.reason}, *se, test.src)
+		}
+	}
+
+	f, err := os.Open("./../../assets/.env.buggy")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	err = dotenv.NewDecoder(f).Decode(&struct{}{})
+	assert.EqualError(t, err, "dotenv: syntax error at ./../../assets/.env.buggy:1:1: missing key\n 1 | =\n   | ^")
+
+	msg := (&decoder.SyntaxError{Line: 12, Column: 3, Text: "\tA='x", Reason: "unterminated quote"}).Error()
+	assert.Equal(t, "dotenv: syntax error at line 12, column 3: unterminated quote\n 12 | \tA='x\n    | \t ^", msg)
+}
+
+func TestLoad_Inline_Comments(t *testing.T) {
+	src := "URL=https://x/app#section\nCOLOR=#ff0000\nA=1 # comment\nB= # empty\nC='#' # quoted\n"
+	tests := map[dialect.Comments][]string{
+		dialect.CommentsAnywhere:   {"https://x/app", "", "1", "", "#"},
+		dialect.CommentsAfterSpace: {"https://x/app#section", "#ff0000", "1", "", "#"},
+		dialect.CommentsNone:       {"https://x/app#section", "#ff0000", "1 # comment", "# empty", "#"},
+	}
+	for rule, expected := range tests {
+		dec := dotenv.NewDecoder([]byte(src))
+		dec.Opts.InlineComments = rule
+
+		entries, err := dec.Parse()
+		if assert.NoError(t, err) && assert.Len(t, entries, len(expected)) {
+			for i, e := range entries {
+				assert.Equal(t, expected[i], e.Value, "%v: %v", rule, e.Key)
+			}
+		}
+	}
+
+	//Decoders built without options strip comments like those built with the default ones
+	entries, err := decoder.Decoder{Src: strings.NewReader(src)}.Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1", entries[2].Value)
+		assert.Equal(t, "# comment", entries[2].Comment)
+	}
+
+	//Other dialects follow their own rules
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Dialect = dialect.Compose
+	dec.Opts.InlineComments = dialect.CommentsAnywhere
+	entries, err = dec.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, "#ff0000", entries[1].Value)
+}
+
+func TestLoad_Empty_And_Bare_Keys(t *testing.T) {
+	os.Setenv("DOTENV_TEST_INHERITED", "from-env")
+	defer os.Unsetenv("DOTENV_TEST_INHERITED")
+
+	src := "EMPTY=\nQUOTED=\"\"\nDOTENV_TEST_INHERITED\nDOTENV_TEST_MISSING # not in the environment\nREF=${DOTENV_TEST_MISSING:-fallback}\n"
+	type Config struct {
+		Empty     string `env:"EMPTY"`
+		Quoted    string `env:"QUOTED"`
+		Inherited string `env:"DOTENV_TEST_INHERITED"`
+		Missing   string `env:"DOTENV_TEST_MISSING"`
+		Ref       string `env:"REF"`
+	}
+
+	//Bare keys are syntax errors in the default dialect unless enabled
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Interpolate = true
+	err := dec.Decode(&Config{})
+	assert.Error(t, err)
+
+	newDecoder := func(bare dialect.BareKeys, skipEmpty bool) *decoder.Decoder {
+		dec := dotenv.NewDecoder([]byte(src))
+		dec.Opts.Interpolate = true
+		dec.Opts.BareKeys = bare
+		dec.Opts.SkipEmpty = skipEmpty
+		return dec
+	}
+
+	entries, err := newDecoder(dialect.BareKeysInherit, false).Parse()
+	if assert.NoError(t, err) && assert.Len(t, entries, 5) {
+		assert.Equal(t, decoder.Entry{Key: "EMPTY", Line: 1}, entries[0])
+		assert.Equal(t, decoder.Entry{Key: "QUOTED", Quote: '"', Line: 2}, entries[1])
+		assert.Equal(t, decoder.Entry{Key: "DOTENV_TEST_INHERITED", Value: "from-env", Bare: true, Line: 3}, entries[2])
+		assert.Equal(t, decoder.Entry{Key: "DOTENV_TEST_MISSING", Comment: "# not in the environment", Bare: true, Unset: true, Line: 4}, entries[3])
+	}
+
+	c := Config{"keep", "keep", "keep", "keep", "keep"}
+	assert.NoError(t, newDecoder(dialect.BareKeysInherit, false).Decode(&c))
+	assert.Equal(t, Config{"", "", "from-env", "keep", "fallback"}, c)
+
+	//Unquoted empty values may leave fields alone too
+	c = Config{"keep", "keep", "keep", "keep", "keep"}
+	assert.NoError(t, newDecoder(dialect.BareKeysInherit, true).Decode(&c))
+	assert.Equal(t, Config{"keep", "", "from-env", "keep", "fallback"}, c)
+
+	//Bare keys never inherit if the dialect lists them without a value
+	c = Config{}
+	assert.NoError(t, newDecoder(dialect.BareKeysUnset, false).Decode(&c))
+	assert.Equal(t, "", c.Inherited)
+
+	//Docker and Compose inherit by default
+	c = Config{}
+	dec = dotenv.NewDecoder([]byte("DOTENV_TEST_INHERITED\n"))
+	dec.Opts.Dialect = dialect.Docker
+	assert.NoError(t, dec.Decode(&c))
+	assert.Equal(t, "from-env", c.Inherited)
+}
+
+func TestLoad_Includes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	decode := func(path string) ([]decoder.Entry, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		dec := dotenv.NewDecoder(f)
+		dec.Opts.Interpolate = true
+		dec.Opts.Includes = true
+		return dec.Parse()
+	}
+
+	write("shared/db.env", "DB_HOST=db\nDB_URL=postgres://${DB_HOST}/${SERVICE}\n")
+	write("shared/all.env", "@include ./db.env\n#include? ./missing.env\n")
+	main := write("service/.env", "SERVICE=api\n#include ../shared/all.env\nDB_HOST=override\n")
+
+	//Included entries take the place of the directive; references resolve across files
+	entries, err := decode(main)
+	if assert.NoError(t, err) && assert.Len(t, entries, 4) {
+		assert.Equal(t, "SERVICE", entries[0].Key)
+		assert.Equal(t, decoder.Entry{Key: "DB_HOST", Raw: "db", Value: "db", File: filepath.Join(dir, "shared/db.env"), Line: 1}, entries[1])
+		assert.Equal(t, "postgres://db/api", entries[2].Value)
+		assert.Equal(t, main, entries[3].File)
+	}
+
+	//Directives are comments unless enabled
+	entries, err = dotenv.NewDecoder([]byte("#include ./missing.env\nA=1\n")).Parse()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	//Required files must exist
+	bad := write("missing.env", "#include ./nope.env\n")
+	_, err = decode(bad)
+	assert.ErrorContains(t, err, "dotenv: cannot include `./nope.env` at "+bad+":1; err: ")
+
+	//Cycles are reported with the chain of files
+	a := write("cycle/a.env", "A=1\n#include b.env\n")
+	b := write("cycle/b.env", "@include ./a.env\n")
+	_, err = decode(a)
+	assert.EqualError(t, err, fmt.Sprintf("dotenv: include cycle detected at %v:1: %v -> %v -> %v", b, a, b, filepath.Join(dir, "cycle/a.env")))
+
+	//Errors name the file each entry was read from
+	shared := write("dup/shared.env", "PORT=80\n")
+	dup := write("dup/.env", "#include shared.env\nPORT=8080\n")
+	f, err := os.Open(dup)
+	assert.NoError(t, err)
+	defer f.Close()
+	dec := dotenv.NewDecoder(f)
+	dec.Opts.Includes = true
+	dec.Opts.Duplicates = decoder.DuplicateError
+	_, err = dec.Parse()
+	assert.EqualError(t, err, fmt.Sprintf("dotenv: duplicate key `PORT` in %v:1 and %v:2", shared, dup))
+
+	unset := write("unset/.env", "URL=${PORT:?is required}\n")
+	_, err = decode(unset)
+	assert.EqualError(t, err, fmt.Sprintf("dotenv: error in %v:1; err: dotenv: `PORT`: is required", unset))
+
+	//Included files count towards the size limit of the data source
+	big := write("size/big.env", "A=0123456789\n")
+	small := write("size/.env", "#include big.env\n")
+	limit := func(max int64) error {
+		f, err := os.Open(small)
+		assert.NoError(t, err)
+		defer f.Close()
+		dec := dotenv.NewDecoder(f)
+		dec.Opts.Includes = true
+		dec.Opts.MaxBytes = max
+		_, err = dec.Parse()
+		return err
+	}
+	assert.NoError(t, limit(30))
+	assert.EqualError(t, limit(29), fmt.Sprintf("dotenv: %v exceeds the maximum size of 29 bytes, counting the files that include it", big))
+	assert.EqualError(t, limit(16), fmt.Sprintf("dotenv: %v exceeds the maximum size of 16 bytes", small))
+}
+
+func TestLoad_Strict_Keys(t *testing.T) {
+	src := "APP_NAME=a\napp.name=b\nexport  1ST=c\n"
+
+	//Any key is accepted by default
+	entries, err := dotenv.NewDecoder([]byte(src)).Parse()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.StrictKeys = true
+	_, err = dec.Parse()
+	assert.EqualError(t, err, "dotenv: syntax error at line 2, column 1: key does not match the allowed pattern\n 2 | app.name=b\n   | ^")
+
+	dec = dotenv.NewDecoder([]byte(src))
+	dec.Opts.StrictKeys = true
+	dec.Opts.KeyPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_.]*`)
+	_, err = dec.Parse()
+	var se *decoder.SyntaxError
+	if assert.ErrorAs(t, err, &se) {
+		assert.Equal(t, 3, se.Line)
+		assert.Equal(t, 9, se.Column)
+	}
+
+	//Every alternative of the pattern is tried against the whole key
+	dec = dotenv.NewDecoder([]byte("AB=1\nA=2\n"))
+	dec.Opts.StrictKeys = true
+	dec.Opts.KeyPattern = regexp.MustCompile(`A|AB`)
+	entries, err = dec.Parse()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestLoad_Sections(t *testing.T) {
+	src := "NAME=app\nHOST=localhost\nURL=http://${HOST}\n\n[production]\nHOST=example.com\nDEBUG=false # off\n\n[staging : production]\nHOST=staging.example.com\n\n[development]\nDEBUG=true\n"
+	parse := func(section string) ([]decoder.Entry, error) {
+		dec := dotenv.NewDecoder([]byte(src))
+		dec.Opts.Interpolate = true
+		dec.Opts.Section = section
+		return dec.Parse()
+	}
+
+	//Only keys outside any section are read unless a section is selected
+	entries, err := parse("")
+	if assert.NoError(t, err) && assert.Len(t, entries, 3) {
+		assert.Equal(t, "http://localhost", entries[2].Value)
+	}
+
+	//Sections override the keys they inherit, and references resolve against the merged keys
+	entries, err = parse("staging")
+	if assert.NoError(t, err) && assert.Len(t, entries, 4) {
+		assert.Equal(t, decoder.Entry{Key: "DEBUG", Raw: "false", Value: "false", Comment: "# off", Section: "production", Line: 7}, entries[2])
+		assert.Equal(t, decoder.Entry{Key: "HOST", Raw: "staging.example.com", Value: "staging.example.com", Section: "staging", Line: 10}, entries[3])
+	}
+
+	config := struct {
+		Name  string `env:"NAME"`
+		Host  string `env:"HOST"`
+		Debug bool   `env:"DEBUG"`
+	}{}
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Section = "development"
+	dec.Opts.Duplicates = decoder.DuplicateError
+	assert.NoError(t, dec.Decode(&config))
+	assert.Equal(t, "app", config.Name)
+	assert.Equal(t, "localhost", config.Host)
+	assert.True(t, config.Debug)
+
+	_, err = parse("qa")
+	assert.EqualError(t, err, "dotenv: section `qa` is not defined")
+
+	src = "[a : b]\n[b : c]\n"
+	_, err = parse("a")
+	assert.EqualError(t, err, "dotenv: section `b` inherits from undefined section `c`")
+
+	src = "[a : b]\n[b : a]\n"
+	_, err = parse("a")
+	assert.EqualError(t, err, "dotenv: section inheritance cycle detected: a -> b -> a")
+}
+
+func TestLoad_Command_Substitution(t *testing.T) {
+	parse := func(src string, commands ...string) ([]decoder.Entry, error) {
+		dec := dotenv.NewDecoder([]byte(src))
+		dec.Opts.Commands = commands
+		return dec.Parse()
+	}
+
+	//Substitutions are rejected unless their command is allowed
+	_, err := parse("REV=$(git rev-parse HEAD)\n")
+	assert.EqualError(t, err, "dotenv: error in line 1; err: dotenv: command substitution `$(git rev-parse HEAD)` is disabled; list the commands it may run in `Opts.Commands`")
+	_, err = parse("SECRET=$(cat /run/secrets/x)\n", "echo")
+	assert.EqualError(t, err, "dotenv: error in line 1; err: dotenv: command `cat` is not in the allowed commands")
+
+	//Arguments are split like shell words, but no shell runs the command
+	entries, err := parse("A=$(echo  'hello   world' \"(x)\")\nB=\"v=$(echo 1)\"\nC='$(echo 1)'\n", "echo")
+	if assert.NoError(t, err) && assert.Len(t, entries, 3) {
+		assert.Equal(t, "hello   world (x)", entries[0].Value)
+		assert.Equal(t, "v=1", entries[1].Value)
+		assert.Equal(t, "$(echo 1)", entries[2].Value)
+	}
+	_, err = parse("A=$(echo x | tr x y)\n", "echo")
+	var se *decoder.SyntaxError
+	if assert.ErrorAs(t, err, &se) {
+		assert.Equal(t, "shell syntax `|` is not supported in command substitution `$(echo x | tr x y)`", se.Reason)
+	}
+
+	//Commands are stopped when they run too long or write too much
+	dec := dotenv.NewDecoder([]byte("A=$(sleep 5)\n"))
+	dec.Opts.Commands = []string{"sleep"}
+	dec.Opts.CommandTimeout = 50 * time.Millisecond
+	_, err = dec.Parse()
+	assert.EqualError(t, err, "dotenv: error in line 1; err: dotenv: command `sleep 5` failed; err: timed out after 50ms")
+
+	dec = dotenv.NewDecoder([]byte("A=$(echo hello)\n"))
+	dec.Opts.Commands = []string{"echo"}
+	dec.Opts.MaxCommandOutput = 3
+	_, err = dec.Parse()
+	assert.EqualError(t, err, "dotenv: error in line 1; err: dotenv: command `echo hello` failed; err: output exceeds the maximum size of 3 bytes")
+
+	//Substitutions do not depend on interpolation, which leaves variable references in place
+	entries, err = parse("A=$(echo 1)${B}\n", "echo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1${B}", entries[0].Value)
+	}
+
+	//Dialects that take values verbatim keep them
+	dec = dotenv.NewDecoder([]byte("A=$(echo 1)\n"))
+	dec.Opts.Dialect = dialect.Systemd
+	entries, err = dec.Parse()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "$(echo 1)", entries[0].Value)
+	}
+}
+
+func TestLoad_Lenient(t *testing.T) {
+	src := "NAME=app\nPORT=80\nbroken line\nURL=http://${HOST}/#top\nCOLOR=#fff\nDEBUG='true\nPORT=8080\n"
+	config := struct {
+		Name  string `env:"NAME"`
+		Port  int    `env:"PORT"`
+		URL   string `env:"URL"`
+		Debug bool   `env:"DEBUG"`
+	}{}
+
+	//Malformed lines fail the decoding by default
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Duplicates = decoder.DuplicateError
+	_, err := dec.DecodeWithWarnings(&config)
+	assert.Error(t, err)
+
+	//Lenient decoders skip them, and duplicate keys no longer fail
+	dec = dotenv.NewDecoder([]byte(src))
+	dec.Opts.Interpolate = true
+	dec.Opts.Duplicates = decoder.DuplicateError
+	dec.Opts.Lenient = true
+	warnings, err := dec.DecodeWithWarnings(&config)
+	assert.NoError(t, err)
+	assert.Equal(t, "app", config.Name)
+	assert.Equal(t, 8080, config.Port)
+	assert.Equal(t, "http:///", config.URL)
+	assert.False(t, config.Debug)
+
+	messages := []string{}
+	for _, w := range warnings {
+		messages = append(messages, w.String())
+	}
+	assert.Equal(t, []string{
+		"dotenv: line 3: skipped malformed line; missing `=` after the key at column 12",
+		"dotenv: line 4: `#` right after the value of `URL` starts a comment; quote the value to keep it",
+		"dotenv: line 5: `#` right after the value of `COLOR` starts a comment; quote the value to keep it",
+		"dotenv: line 6: skipped malformed line; unterminated quote at column 7",
+		"dotenv: line 7: duplicate key `PORT`, first defined in line 2",
+		"dotenv: line 4: variable `HOST` is not set and expands to an empty string",
+	}, messages)
+
+	//Plain decoding stays silent
+	dec = dotenv.NewDecoder([]byte(src))
+	dec.Opts.Lenient = true
+	assert.NoError(t, dec.Decode(&config))
+}
+
+// Log level decoded through `encoding.TextUnmarshaler`
+type Level int
+
+func (l *Level) UnmarshalText(text []byte) error {
+	for i, name := range []string{"debug", "info", "warn", "error"} {
+		if string(text) == name {
+			*l = Level(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown level %q", text)
+}
+
+// Region code decoded through `decoder.Unmarshaler`; it records where it was defined
+type Region struct {
+	Code  string
+	Where string
+}
+
+func (r *Region) UnmarshalEnv(key, value string, pos decoder.Position) error {
+	if len(value) != 2 {
+		return fmt.Errorf("%v at line %v: invalid region %q", key, pos.Line, value)
+	}
+	r.Code, r.Where = value, fmt.Sprintf("%v@%v", key, pos.Line)
+
+	return nil
+}
+
+var _ dotenv.Unmarshaler = &Region{}
+
+func TestLoad_Unmarshalers(t *testing.T) {
+	config := struct {
+		Level   Level     `env:"LEVEL"`
+		Levels  []Level   `env:"LEVELS"`
+		Region  *Region   `env:"REGION"`
+		Regions []*Region `env:"REGIONS"`
+		Home    Region    `env:"HOME"`
+	}{}
+	src := "LEVEL=warn\nLEVELS=debug, error\nREGION=eu\nREGIONS=us,ap\nHOME=de\n"
+	assert.NoError(t, dotenv.NewDecoder([]byte(src)).Decode(&config))
+	assert.Equal(t, Level(2), config.Level)
+	assert.Equal(t, []Level{0, 3}, config.Levels)
+	assert.Equal(t, &Region{"eu", "REGION@3"}, config.Region)
+	assert.Equal(t, []*Region{{"us", "REGIONS@4"}, {"ap", "REGIONS@4"}}, config.Regions)
+	assert.Equal(t, Region{"de", "HOME@5"}, config.Home)
+
+	//Collected duplicates fill slices with the elements of every occurrence
+	dec := dotenv.NewDecoder([]byte("LEVELS=info\nLEVELS=warn,debug\n"))
+	dec.Opts.Duplicates = decoder.DuplicateCollect
+	assert.NoError(t, dec.Decode(&config))
+	assert.Equal(t, []Level{1, 2, 0}, config.Levels)
+
+	err := dotenv.NewDecoder([]byte("LEVEL=loud\n")).Decode(&config)
+	assert.EqualError(t, err, "dotenv: cannot set `Level` field; err: unknown level \"loud\"")
+	err = dotenv.NewDecoder([]byte("A=1\nREGIONS=us,europe\n")).Decode(&config)
+	assert.EqualError(t, err, "dotenv: cannot set `Regions` field; err: REGIONS at line 2: invalid region \"europe\"")
+}
+
+func TestLoad_Std_Types(t *testing.T) {
+	config := struct {
+		Timeout time.Duration  `env:"TIMEOUT"`
+		Day     time.Time      `env:"DAY,layout=2006-01-02"`
+		Mode    os.FileMode    `env:"MODE"`
+		Subnets []*net.IPNet   `env:"SUBNETS"`
+		Pattern *regexp.Regexp `env:"PATTERN"`
+	}{}
+	src := "TIMEOUT=1m30s\nDAY=2024-05-01\nMODE=0o755\nSUBNETS=10.0.0.0/8, 192.168.0.0/16\nPATTERN=^a+$\n"
+	assert.NoError(t, dotenv.NewDecoder([]byte(src)).Decode(&config))
+	assert.Equal(t, 90*time.Second, config.Timeout)
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), config.Day)
+	assert.Equal(t, os.FileMode(0755), config.Mode)
+	if assert.Len(t, config.Subnets, 2) {
+		assert.Equal(t, "192.168.0.0/16", config.Subnets[1].String())
+	}
+	assert.True(t, config.Pattern.MatchString("aaa"))
+
+	for src, msg := range map[string]string{
+		"TIMEOUT=30":       "dotenv: cannot set `Timeout` field; err: time: missing unit in duration \"30\"",
+		"DAY=01/05/2024":   "dotenv: cannot set `Day` field; err: parsing time \"01/05/2024\" as \"2006-01-02\": cannot parse \"01/05/2024\" as \"2006\"",
+		"MODE=rw-r--r--":   "dotenv: cannot set `Mode` field; err: invalid octal file mode \"rw-r--r--\"",
+		"PATTERN=a(":       "dotenv: cannot set `Pattern` field; err: error parsing regexp: missing closing ): `a(`",
+		"SUBNETS=10.0.0.1": "dotenv: cannot set `Subnets` field; err: invalid CIDR address: 10.0.0.1",
+	} {
+		assert.EqualError(t, dotenv.NewDecoder([]byte(src)).Decode(&config), msg)
+	}
+}
+
+func TestLoad_Optional_Pointers(t *testing.T) {
+	type Optional struct {
+		Port    *int           `env:"PORT"`
+		Debug   *bool          `env:"DEBUG"`
+		Name    *string        `env:"NAME"`
+		Hosts   *[]string      `env:"HOSTS"`
+		Timeout *time.Duration `env:"TIMEOUT"`
+		Big     *big.Int       `env:"BIG"`
+	}
+
+	//Absent keys leave pointers nil, so that zero values can be told apart from missing ones
+	config := Optional{}
+	assert.NoError(t, dotenv.NewDecoder([]byte("PORT=0\nNAME=\nTIMEOUT=5s\n")).Decode(&config))
+	if assert.NotNil(t, config.Port) && assert.NotNil(t, config.Name) && assert.NotNil(t, config.Timeout) {
+		assert.Equal(t, 0, *config.Port)
+		assert.Equal(t, "", *config.Name)
+		assert.Equal(t, 5*time.Second, *config.Timeout)
+	}
+	assert.Nil(t, config.Debug)
+	assert.Nil(t, config.Hosts)
+
+	//Values are written through pointers that are already set
+	port := config.Port
+	assert.NoError(t, dotenv.NewDecoder([]byte("PORT=8080\nDEBUG=true\nHOSTS=a,b\n")).Decode(&config))
+	assert.Same(t, port, config.Port)
+	assert.Equal(t, 8080, *port)
+	if assert.NotNil(t, config.Debug) && assert.NotNil(t, config.Hosts) {
+		assert.True(t, *config.Debug)
+		assert.Equal(t, []string{"a", "b"}, *config.Hosts)
+	}
+
+	//Unset and skipped entries count as absent
+	config = Optional{}
+	dec := dotenv.NewDecoder([]byte("PORT=\nDEBUG\n"))
+	dec.Opts.SkipEmpty = true
+	dec.Opts.BareKeys = dialect.BareKeysUnset
+	assert.NoError(t, dec.Decode(&config))
+	assert.Equal(t, Optional{}, config)
+
+	//Values that fail to decode leave pointers alone
+	err := dotenv.NewDecoder([]byte("PORT=x\n")).Decode(&config)
+	assert.Error(t, err)
+	assert.Nil(t, config.Port)
+
+	err = dotenv.NewDecoder([]byte("BIG=x\n")).Decode(&config)
+	assert.Error(t, err)
+	assert.Nil(t, config.Big)
+
+	config.Port = port
+	err = dotenv.NewDecoder([]byte("PORT=x\n")).Decode(&config)
+	assert.Error(t, err)
+	assert.Same(t, port, config.Port)
+	assert.Equal(t, 8080, *port)
+}
+
+func TestParse(t *testing.T) {
+	src := "# Header\nA=1 # one\nexport B=\"${A}\\n2\"\nC='x\ny' # two\n# Not inline\nA=3\nD=<<EOF # doc\nbody\nEOF\n"
+	entries, err := decoder.Parse(strings.NewReader(src))
+	assert.NoError(t, err)
+	assert.Equal(t, []decoder.Entry{
+		{Key: "A", Raw: "1", Value: "1", Comment: "# one", Line: 2},
+		{Key: "B", Raw: "${A}\\n2", Value: "${A}\n2", Quote: '"', Line: 3},
+		{Key: "C", Raw: "x\ny", Value: "x\ny", Quote: '\'', Comment: "# two", Line: 4},
+		{Key: "A", Raw: "3", Value: "3", Line: 7},
+		{Key: "D", Raw: "body", Value: "body", Comment: "# doc", Line: 8},
+	}, entries)
+
+	//The decoder's options apply
+	dec := dotenv.NewDecoder([]byte(src))
+	dec.Opts.Interpolate = true
+	entries, err = dec.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2", entries[1].Value)
+
+	dec = dotenv.NewDecoder([]byte(src))
+	dec.Opts.Duplicates = decoder.DuplicateError
+	_, err = dec.Parse()
+	assert.EqualError(t, err, "dotenv: duplicate key `A` in line 2 and line 7")
+
+	_, err = decoder.Parse(strings.NewReader("A='x"))
+	assert.Error(t, err)
+}
+
+// benchSource generates a dotenv file with the given number of entries, mixing the common value styles.
+func benchSource(n int) []byte {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&sb, "# Entry %v\nKEY_%v=value_%v\n", i, i, i)
+		case 1:
+			fmt.Fprintf(&sb, "KEY_%v = \"double quoted value %v\" # comment\n", i, i)
+		case 2:
+			fmt.Fprintf(&sb, "export KEY_%v='single quoted value %v'\n\n", i, i)
+		case 3:
+			fmt.Fprintf(&sb, "KEY_%v=%v,%v,%v\n", i, i, i+1, i+2)
+		}
+	}
+
+	return []byte(sb.String())
+}
+
+func benchmarkDecode(b *testing.B, src []byte) {
+	c := &Config{FlagBox: &FlagBox{}}
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := dotenv.NewDecoder(src).Decode(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode_Small(b *testing.B) {
+	src, err := os.ReadFile("./../../assets/.env")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	benchmarkDecode(b, src)
+}
+
+func BenchmarkDecode_Large(b *testing.B) {
+	benchmarkDecode(b, benchSource(10000))
+}
+
+func BenchmarkDecode_LongValue(b *testing.B) {
+	benchmarkDecode(b, []byte("LONG=\""+strings.Repeat("x", 1<<20)+"\"\n"))
+}
+
+func BenchmarkDecode_Multiline(b *testing.B) {
+	benchmarkDecode(b, []byte("CERT=\""+strings.Repeat("MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA\n", 2000)+"\"\n"))
+}
@@ -0,0 +1,58 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/golobby/dotenv/v2/pkg/lexer"
+)
+
+// include reads the file named by an include directive and appends its entries to the given ones.
+// Relative paths are resolved against the directory of the including file, or the working directory if it has no name.
+func (d Decoder) include(t lexer.Token, lines []Entry, stack []string, sections map[string]string) ([]Entry, error) {
+	path := t.Text
+	if !filepath.IsAbs(path) && d.Name != "" {
+		path = filepath.Join(filepath.Dir(d.Name), path)
+	}
+
+	where := fmt.Sprintf("line %v", t.Pos.Line)
+	if d.Name != "" {
+		where = fmt.Sprintf("%v:%v", d.Name, t.Pos.Line)
+	}
+
+	//A file may not include itself, directly or through other files
+	for _, name := range stack {
+		if samePath(name, path) {
+			chain := strings.Join(append(stack, path), " -> ")
+			return nil, fmt.Errorf("dotenv: include cycle detected at %v: %v", where, chain)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if t.Kind == lexer.OptionalInclude && errors.Is(err, fs.ErrNotExist) {
+			return lines, nil
+		}
+		return nil, fmt.Errorf("dotenv: cannot include `%v` at %v; err: %v", t.Text, where, err)
+	}
+	defer f.Close()
+
+	inc := d
+	inc.Name = path
+	return inc.read(f, lines, append(stack, path), sections)
+}
+
+// samePath reports whether two file paths refer to the same file.
+func samePath(a, b string) bool {
+	if fa, err := os.Stat(a); err == nil {
+		if fb, err := os.Stat(b); err == nil {
+			return os.SameFile(fa, fb)
+		}
+	}
+
+	return filepath.Clean(a) == filepath.Clean(b)
+}
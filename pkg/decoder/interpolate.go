@@ -0,0 +1,353 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+	"github.com/golobby/dotenv/v2/pkg/lexer"
+)
+
+// Expands the escape sequences and variable references found in the values of a file's entries.
+type _Resolver struct {
+	opts  DecoderOpts
+	rules dialect.Rules
+	warn  func(file string, line int, format string, args ...interface{})
+	lines []Entry
+	index map[string]int //Position in `lines` of the last definition of each key resolved so far, if it provides a value.
+	depth int            //Current nesting of the entry's value and modifier words.
+
+	outputs map[string]string //Output of each command substitution that has run, by command.
+}
+
+// Identifies an entry by the data source and line it was defined on.
+type _Place struct {
+	File string
+	Line int
+}
+
+// Represents where an entry's value was read from.
+type _Origin struct {
+	Token lexer.Token //The value or heredoc token.
+	Text  []byte      //The physical lines the token spans.
+}
+
+// Represents a malformed variable reference or command substitution, found while expanding a value.
+type _ReferenceError struct {
+	Ref    string //The offending reference, as written in the value; the rest of the value if it is unterminated.
+	Reason string
+}
+
+func (e *_ReferenceError) Error() string {
+	return fmt.Sprintf("dotenv: %v `%v`", e.Reason, e.Ref)
+}
+
+// resolve expands the raw values of the entries read from the data source, in file order, filling in their decoded values.
+// Keys defined more than once are rejected if the decoder's duplicate policy asks for it.
+func (d Decoder) resolve(lines []Entry) error {
+	r := &_Resolver{
+		opts:  d.Opts,
+		rules: d.rules(),
+		warn:  d.warn,
+		lines: lines,
+		index: make(map[string]int, len(lines)),
+	}
+
+	first := make(map[string]int, len(lines))
+	for i, l := range lines {
+		//Bare keys inherit their value from the environment, if the dialect asks for it
+		if l.Bare {
+			v, ok := os.LookupEnv(l.Key)
+			if ok && r.rules.BareKeys == dialect.BareKeysInherit {
+				lines[i].Value = v
+			} else {
+				lines[i].Unset = true
+				if r.rules.BareKeys == dialect.BareKeysInherit {
+					d.warn(l.File, l.Line, "key `%v` stands alone and is not set in the environment", l.Key)
+				}
+			}
+		}
+
+		j, dup := first[l.Key]
+		if !dup {
+			first[l.Key] = i
+			continue
+		}
+		if d.Opts.Duplicates == DuplicateError && !d.Opts.Lenient {
+			return fmt.Errorf("dotenv: duplicate key `%v` in %v and %v", l.Key, lines[j].where(), l.where())
+		}
+		d.warn(l.File, l.Line, "duplicate key `%v`, first defined in %v", l.Key, lines[j].where())
+	}
+
+	for i, l := range lines {
+		if !l.Bare {
+			v, err := r.nested(l.Raw, l.Quote, i)
+			if err != nil {
+				var re *_ReferenceError
+				if errors.As(err, &re) {
+					return d.locate(l, re)
+				}
+				return fmt.Errorf("dotenv: error in %v; err: %v", l.where(), err)
+			}
+			lines[i].Value = v
+		}
+		if !lines[i].Unset {
+			r.index[l.Key] = i
+		}
+	}
+
+	return nil
+}
+
+// locate converts a malformed reference into a syntax error pointing at its `$` in the source.
+func (d Decoder) locate(e Entry, re *_ReferenceError) error {
+	se := &SyntaxError{File: e.File, Line: e.Line, Column: 1, Reason: fmt.Sprintf("%v `%v`", re.Reason, re.Ref)}
+	o, ok := d.origins[_Place{e.File, e.Line}]
+	if !ok {
+		return se
+	}
+
+	//Raw values keep the line breaks of multiline quotes and heredocs, so the reference lies on the matching physical line
+	off := strings.Index(e.Raw, re.Ref)
+	if off < 0 {
+		off = 0
+	}
+	n := strings.Count(e.Raw[:off], "\n")
+	start := strings.LastIndexByte(e.Raw[:off], '\n') + 1
+	lines := strings.Split(string(o.Text), "\n")
+	if o.Token.Kind == lexer.Heredoc {
+		//The body starts on the line after the marker, and may have lost its indentation
+		n++
+	}
+	if n >= len(lines) {
+		n = len(lines) - 1
+	}
+
+	se.Line = o.Token.Pos.Line + n
+	se.Text = lines[n]
+	switch {
+	case n == 0 && e.Quote != 0:
+		se.Column = o.Token.Pos.Column + 1 + off
+	case n == 0:
+		se.Column = o.Token.Pos.Column + off
+	default:
+		end := strings.IndexByte(e.Raw[start:], '\n')
+		if end < 0 {
+			end = len(e.Raw) - start
+		}
+		indent := 0
+		if o.Token.Kind == lexer.Heredoc {
+			indent = len(se.Text) - end
+		}
+		se.Column = indent + off - start + 1
+	}
+
+	return se
+}
+
+// lookup finds the value of the named variable as seen from the entry being resolved: the closest preceding
+// definition in the file that provides a value wins, and the process environment is consulted next if enabled.
+// A key never refers to its own entry, so `PATH=$PATH:/bin` extends an earlier or inherited value.
+func (r *_Resolver) lookup(name string) (string, bool) {
+	if j, ok := r.index[name]; ok {
+		return r.lines[j].Value, true
+	}
+
+	if r.opts.UseEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// nested expands an entry's value or a modifier word one level deeper than the current one, enforcing the maximum interpolation depth.
+func (r *_Resolver) nested(raw string, quote byte, at int) (string, error) {
+	r.depth++
+	defer func() { r.depth-- }()
+
+	//The entry's own value counts as the first level
+	if limit := r.opts.MaxInterpolationDepth; limit > 0 && r.depth > limit {
+		return "", fmt.Errorf("dotenv: interpolation exceeds the maximum depth of %v", limit)
+	}
+
+	return r.expand(raw, quote, at)
+}
+
+// expand decodes a raw value. Escape sequences are processed as the dialect defines them for the value's quote style,
+// and variable references are substituted in unquoted and double-quoted values if the dialect interpolates.
+// Command substitutions are run in those values too, and in the default dialect even if interpolation is off.
+func (r *_Resolver) expand(raw string, quote byte, at int) (string, error) {
+	escapes := ""
+	interpolate := r.rules.Interpolate
+	//A substitution left in place would silently become part of the value, so the default dialect always recognizes them
+	commands := interpolate || r.opts.Dialect == dialect.Default
+	switch quote {
+	case '"':
+		escapes = r.rules.DoubleEscapes
+	case '\'':
+		escapes = r.rules.SingleEscapes
+		interpolate, commands = false, false
+	case '`':
+		return raw, nil
+	}
+
+	//Most values hold neither escapes nor references; return them without copying
+	if strings.IndexAny(raw, "\\$") < 0 {
+		return raw, nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(raw))
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == '\\' && quote == 0 && r.rules.UnquotedEscapes && i+1 < len(raw):
+			sb.WriteByte(raw[i+1])
+			i++
+		case raw[i] == '\\' && escapes != "" && i+1 < len(raw):
+			esc, n := unescape(raw[i+1:], escapes)
+			sb.WriteString(esc)
+			i += n
+		case raw[i] == '$' && commands && strings.HasPrefix(raw[i:], "$("):
+			v, n, err := r.command(raw[i:])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+			i += n - 1
+		case raw[i] == '$' && interpolate:
+			v, n, err := r.reference(raw[i:], quote, at)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(v)
+			i += n - 1
+		default:
+			sb.WriteByte(raw[i])
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// reference substitutes the variable reference at the start of the given string, which begins with `$`.
+// It returns the substituted text and the number of bytes the reference spans.
+func (r *_Resolver) reference(s string, quote byte, at int) (string, int, error) {
+	//Short form: `$VAR`; a lone `$` is kept as-is, as are short forms in dialects that only recognize braces
+	if len(s) < 2 || s[1] != '{' {
+		if r.rules.BracedOnly {
+			return "$", 1, nil
+		}
+
+		name := s[1 : 1+identLen(s[1:])]
+		if name == "" {
+			return "$", 1, nil
+		}
+
+		v, set := r.lookup(name)
+		if !set {
+			r.warn(r.lines[at].File, r.lines[at].Line, "variable `%v` is not set and expands to an empty string", name)
+		}
+		return v, 1 + len(name), nil
+	}
+
+	//Braced form: `${VAR}`, optionally followed by a POSIX modifier
+	end := closingBrace(s, quote)
+	if end < 0 {
+		return "", 0, &_ReferenceError{s, "unterminated variable reference"}
+	}
+	body := s[2:end]
+	name := body[:identLen(body)]
+	rest := body[len(name):]
+	if name == "" {
+		return "", 0, &_ReferenceError{s[:end+1], "bad substitution"}
+	}
+
+	v, set := r.lookup(name)
+	if rest == "" {
+		if !set {
+			r.warn(r.lines[at].File, r.lines[at].Line, "variable `%v` is not set and expands to an empty string", name)
+		}
+		return v, end + 1, nil
+	}
+
+	//With a colon, the modifiers treat empty variables as if they were unset
+	colon := strings.HasPrefix(rest, ":")
+	if colon {
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return "", 0, &_ReferenceError{s[:end+1], "bad substitution"}
+	}
+	unset := !set || (colon && v == "")
+	word := rest[1:]
+
+	var err error
+
+	switch rest[0] {
+	case '-':
+		//`${VAR:-default}` / `${VAR-default}`
+		if unset {
+			v, err = r.nested(word, quote, at)
+		}
+	case '+':
+		//`${VAR:+alt}` / `${VAR+alt}`
+		v = ""
+		if !unset {
+			v, err = r.nested(word, quote, at)
+		}
+	case '?':
+		//`${VAR:?message}` / `${VAR?message}`
+		if unset {
+			msg, err := r.nested(word, quote, at)
+			if err != nil {
+				return "", 0, err
+			}
+			if msg == "" {
+				msg = "parameter null or not set"
+			}
+			return "", 0, fmt.Errorf("dotenv: `%v`: %v", name, msg)
+		}
+	default:
+		return "", 0, &_ReferenceError{s[:end+1], "bad substitution"}
+	}
+
+	return v, end + 1, err
+}
+
+// closingBrace returns the position of the brace that closes the `${` at the start of the given string, or -1.
+// Nested references are skipped over, as are escaped characters in double-quoted values.
+func closingBrace(s string, quote byte) int {
+	depth := 0
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == '{' && s[i-1] == '$':
+			depth++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// identLen returns the length of the variable name (`[A-Za-z_][A-Za-z0-9_]*`) at the start of the given string.
+func identLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return i
+	}
+
+	return len(s)
+}
@@ -0,0 +1,106 @@
+package decoder
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+	"github.com/golobby/dotenv/v2/pkg/lexer"
+)
+
+// Represents a set of options for the decoder.
+type DecoderOpts struct {
+	Dialect dialect.Dialect //The syntax variant to parse; `Interpolate`, `InlineComments` and `BareKeys` only apply to the default dialect, the others define them themselves.
+
+	Interpolate bool //Whether to expand `${VAR}` and `$VAR` references in unquoted and double-quoted values; off by default, so that `$` is kept as written.
+	UseEnv      bool //Whether references to keys that are not defined earlier in the file may resolve against the process environment; off by default.
+
+	InlineComments dialect.Comments //Where a `#` inside an unquoted value starts a comment. `dialect.CommentsAfterSpace` keeps values like `#ff0000` and URL fragments intact, and is recommended.
+	BareKeys       dialect.BareKeys //How keys that stand alone, without `=` and a value, are read; they leave fields alone unless they inherit a value.
+	SkipEmpty      bool             //Whether unquoted empty values (`KEY=`) leave fields alone; quoted empty strings (`KEY=""`) always set them.
+
+	Includes bool   //Whether `#include path` and `@include path` lines read other files, relative to the including one; `#include?` and `@include?` skip missing files.
+	Section  string //The `[section]` whose keys are merged over the keys outside any section, along with the sections it inherits from; if empty, only keys outside any section are read.
+
+	Commands         []string      //Programs that `$(program args...)` substitutions may run, as named in the file; substitutions are rejected if empty, even with `Interpolate` off. No shell is involved, so shell syntax like pipes and redirections is rejected.
+	CommandTimeout   time.Duration //Time a substituted command may run for; 0 means 10 seconds.
+	MaxCommandOutput int64         //Maximum size of a substituted command's output, in bytes; 0 means 1 MiB.
+
+	Duplicates DuplicatePolicy //How keys that are defined more than once are handled; `DuplicateError` is recommended for CI.
+	Lenient    bool            //Whether malformed lines are skipped instead of failing the decoding; duplicate keys then fall back to `DuplicateLastWins`. `DecodeWithWarnings` reports both.
+
+	StrictKeys bool           //Whether keys must match `KeyPattern`; other keys are reported as syntax errors.
+	KeyPattern *regexp.Regexp //Pattern keys must match in full in strict mode; POSIX identifiers (`dialect.Identifier`) if nil.
+
+	MaxLineLength         int   //Maximum length of a physical line, in bytes; 0 means unlimited.
+	MaxKeys               int   //Maximum number of entries in the file; 0 means unlimited.
+	MaxBytes              int64 //Maximum size of the data source, in bytes, counting the files it includes; 0 means unlimited.
+	MaxInterpolationDepth int   //Maximum nesting of modifier words in references, e.g. `${A:-${B:-x}}`, the value itself counting as the first level; 0 means unlimited.
+}
+
+// Represents how the decoder handles keys that are defined more than once.
+type DuplicatePolicy int
+
+const (
+	DuplicateLastWins  DuplicatePolicy = iota //The last definition overrides the earlier ones.
+	DuplicateFirstWins                        //The first definition is kept; later ones are ignored.
+	DuplicateError                            //Decoding fails, reporting the lines of both definitions. Recommended for CI.
+	DuplicateCollect                          //Every definition is kept; slice fields receive all of them, other fields the last one.
+)
+
+// Represents a single dotenv entry, as listed by `Parse`.
+type Entry struct {
+	Key     string
+	Raw     string //The value as written in the source, without its quotes; escape sequences and references are left in place.
+	Value   string //The decoded value, with escape sequences and variable references expanded.
+	Quote   byte   //The quote character that enclosed the value, or 0 if it was unquoted; `'` for heredocs with a quoted delimiter.
+	Comment string //The inline comment following the value, including its `#`, if any.
+	Section string //The section the entry was defined in; empty outside any section.
+	Bare    bool   //Whether the key stands alone, without `=` and a value; its value may be inherited from the environment.
+	Unset   bool   //Whether the entry provides no value: a bare key that does not inherit one. `Decode` leaves its fields alone.
+	File    string //Name of the data source the entry was read from, if known; included files are named by their path.
+	Line    int    //Line the entry starts on.
+}
+
+// where describes the location of the entry, e.g. `line 3`, or `shared.env:3` for entries read from a named source.
+func (e Entry) where() string {
+	if e.File != "" {
+		return fmt.Sprintf("%v:%v", e.File, e.Line)
+	}
+
+	return fmt.Sprintf("line %v", e.Line)
+}
+
+// Returns the default options for the decoder.
+func DefaultOpts() DecoderOpts {
+	return DecoderOpts{
+		dialect.Default,
+		false, false,
+		dialect.CommentsAnywhere, dialect.BareKeysNone, false,
+		false, "",
+		nil, 0, 0,
+		DuplicateLastWins, false,
+		false, nil,
+		0, 0, 0, 0,
+	}
+}
+
+// Represents a problem found in a data source that did not stop it from being decoded, as returned by `DecodeWithWarnings`.
+type Warning struct {
+	File    string //Name of the data source the problem was found in, if known.
+	Line    int
+	Message string
+}
+
+// String formats the warning along with its location.
+func (w Warning) String() string {
+	if w.File != "" {
+		return fmt.Sprintf("dotenv: %v:%v: %v", w.File, w.Line, w.Message)
+	}
+
+	return fmt.Sprintf("dotenv: line %v: %v", w.Line, w.Message)
+}
+
+// Represents a syntax error in a dot env (.env) data source. Use `errors.As` to retrieve it from the decoder's errors.
+type SyntaxError = lexer.SyntaxError
@@ -0,0 +1,56 @@
+package decoder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// section keeps the entries outside any section and those of the selected section and the sections it inherits from.
+// They are layered from the entries outside any section down to the selected section; a key defined in a layer
+// replaces the definitions of earlier layers, so the duplicate policy only applies within a layer.
+func (d Decoder) section(lines []Entry, sections map[string]string) ([]Entry, error) {
+	if d.Opts.Section == "" && len(sections) == 0 {
+		return lines, nil
+	}
+
+	//Walk up the chain of parents, from the selected section to the most distant one
+	chain := []string{}
+	for name := d.Opts.Section; name != ""; name = sections[name] {
+		if _, ok := sections[name]; !ok {
+			if len(chain) == 0 {
+				return nil, fmt.Errorf("dotenv: section `%v` is not defined", name)
+			}
+			return nil, fmt.Errorf("dotenv: section `%v` inherits from undefined section `%v`", chain[len(chain)-1], name)
+		}
+		for _, c := range chain {
+			if c == name {
+				return nil, fmt.Errorf("dotenv: section inheritance cycle detected: %v", strings.Join(append(chain, name), " -> "))
+			}
+		}
+		chain = append(chain, name)
+	}
+
+	layers := map[string]int{"": 0}
+	for i, name := range chain {
+		layers[name] = len(chain) - i
+	}
+
+	//Find the last layer that defines each key
+	top := map[string]int{}
+	for _, e := range lines {
+		if l, ok := layers[e.Section]; ok && l > top[e.Key] {
+			top[e.Key] = l
+		}
+	}
+
+	kept := make([]Entry, 0, len(lines))
+	for l := 0; l <= len(chain); l++ {
+		for _, e := range lines {
+			if layer, ok := layers[e.Section]; ok && layer == l && top[e.Key] == l {
+				kept = append(kept, e)
+			}
+		}
+	}
+
+	return kept, nil
+}
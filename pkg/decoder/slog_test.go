@@ -0,0 +1,34 @@
+//go:build go1.21
+
+package decoder_test
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/golobby/dotenv/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad_Slog_Level(t *testing.T) {
+	config := struct {
+		Level  slog.Level   `env:"LEVEL"`
+		Levels []slog.Level `env:"LEVELS"`
+	}{}
+	assert.NoError(t, dotenv.NewDecoder([]byte("LEVEL=warn\nLEVELS=debug,INFO+2\n")).Decode(&config))
+	assert.Equal(t, slog.LevelWarn, config.Level)
+	assert.Equal(t, []slog.Level{slog.LevelDebug, slog.LevelInfo + 2}, config.Levels)
+
+	//Levels are written by name, as the encoder writes every `encoding.TextMarshaler`
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dotenv.NewEncoder(buf).Encode(&config))
+	assert.Equal(t, "LEVEL=WARN\nLEVELS=DEBUG, INFO+2", buf.String())
+
+	//Levels that fail to decode leave optional pointers nil
+	optional := struct {
+		Level *slog.Level `env:"LEVEL"`
+	}{}
+	assert.Error(t, dotenv.NewDecoder([]byte("LEVEL=loud\n")).Decode(&optional))
+	assert.Nil(t, optional.Level)
+}
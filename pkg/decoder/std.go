@@ -0,0 +1,86 @@
+package decoder
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Standard library types whose values casting cannot read, and that do not implement `encoding.TextUnmarshaler`
+// in every supported Go version. Others, such as `net.IP`, `netip.Addr`, `*big.Int` and `slog.Level`, decode themselves.
+var (
+	durationType  = reflect.TypeOf(time.Duration(0))
+	timeType      = reflect.TypeOf(time.Time{})
+	urlType       = reflect.TypeOf(url.URL{})
+	urlPtrType    = reflect.TypeOf(&url.URL{})
+	ipNetType     = reflect.TypeOf(net.IPNet{})
+	ipNetPtrType  = reflect.TypeOf(&net.IPNet{})
+	regexpPtrType = reflect.TypeOf(&regexp.Regexp{})
+	fileModeType  = reflect.TypeOf(os.FileMode(0))
+)
+
+// isStd reports whether values of the given type are decoded by `decodeStd`.
+func isStd(typ reflect.Type) bool {
+	switch typ {
+	case durationType, timeType, urlType, urlPtrType, ipNetType, ipNetPtrType, regexpPtrType, fileModeType:
+		return true
+	}
+
+	return false
+}
+
+// decodeStd decodes a value into a settable value of a standard library type that casting does not handle,
+// returning false for other types. Times are parsed with the given layout, or as RFC 3339 if it is empty.
+func decodeStd(v reflect.Value, value, layout string) (bool, error) {
+	var x interface{}
+	var err error
+	switch v.Type() {
+	case durationType:
+		x, err = time.ParseDuration(value)
+	case timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		x, err = time.Parse(layout, value)
+	case urlType, urlPtrType:
+		var u *url.URL
+		if u, err = url.Parse(value); err == nil {
+			x = u
+			if v.Type() == urlType {
+				x = *u
+			}
+		}
+	case ipNetType, ipNetPtrType:
+		var n *net.IPNet
+		if _, n, err = net.ParseCIDR(value); err == nil {
+			x = n
+			if v.Type() == ipNetType {
+				x = *n
+			}
+		}
+	case regexpPtrType:
+		x, err = regexp.Compile(value)
+	case fileModeType:
+		//File modes are octal, as `chmod` takes them
+		var n uint64
+		if n, err = strconv.ParseUint(strings.TrimPrefix(value, "0o"), 8, 32); err == nil {
+			x = os.FileMode(n)
+		} else {
+			err = fmt.Errorf("invalid octal file mode %q", value)
+		}
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+
+	v.Set(reflect.ValueOf(x))
+	return true, nil
+}
@@ -0,0 +1,67 @@
+package decoder
+
+import (
+	"encoding"
+	"reflect"
+)
+
+// Implemented by types that decode their own values from a dot env (.env) file. The key and position of the entry
+// are given for error messages; for slice elements, the value is the element being decoded.
+type Unmarshaler interface {
+	UnmarshalEnv(key, value string, pos Position) error
+}
+
+// Represents where an entry is defined.
+type Position struct {
+	File string //Name of the data source, if known; included files are named by their path.
+	Line int
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unmarshals reports whether values of the given type, or pointers to them, decode themselves.
+func unmarshals(typ reflect.Type) bool {
+	for _, t := range []reflect.Type{typ, reflect.PtrTo(typ)} {
+		if t.Implements(unmarshalerType) || t.Implements(textUnmarshalerType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unmarshal decodes the given value of an entry into a settable value through its `Unmarshaler` or
+// `encoding.TextUnmarshaler` implementation, preferring the former; nil pointers are only set once the value decodes.
+// It returns false if neither the value nor its pointer implements them.
+func unmarshal(v reflect.Value, e Entry, value string) (bool, error) {
+	var target reflect.Value
+	switch {
+	case v.Kind() == reflect.Ptr && (v.Type().Implements(unmarshalerType) || v.Type().Implements(textUnmarshalerType)):
+		target = v
+		if v.IsNil() {
+			target = reflect.New(v.Type().Elem())
+		}
+	case v.CanAddr() && unmarshals(v.Type()):
+		target = v.Addr()
+	default:
+		return false, nil
+	}
+
+	var err error
+	if u, ok := target.Interface().(Unmarshaler); ok {
+		err = u.UnmarshalEnv(e.Key, value, Position{e.File, e.Line})
+	} else {
+		err = target.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		v.Set(target)
+	}
+	return true, nil
+}
@@ -0,0 +1,191 @@
+// Package dialect describes the .env syntax variants understood by the tools that commonly read these files.
+package dialect
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Matches POSIX shell identifiers, the keys that every dialect and shell accepts; the default pattern of strict key checks.
+var Identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Anchored copies of the key patterns passed to `ValidKey`, by pattern.
+var anchored sync.Map
+
+// ValidKey reports whether the given pattern matches the whole key.
+// The pattern is anchored at both ends, so that every alternative is tried against the whole key rather than the leftmost match.
+func ValidKey(pattern *regexp.Regexp, key string) bool {
+	re, ok := anchored.Load(pattern)
+	if !ok {
+		re, _ = anchored.LoadOrStore(pattern, regexp.MustCompile(`^(?:`+pattern.String()+`)$`))
+	}
+
+	return re.(*regexp.Regexp).MatchString(key)
+}
+
+// SplitTag splits an `env` struct tag into its key and options, for both the decoder and the encoder.
+// The only option is `layout=...`, the layout of `time.Time` fields; it takes the rest of the tag, so that the layout may hold commas.
+func SplitTag(tag string) (key, layout string) {
+	key, opts, _ := strings.Cut(tag, ",")
+	if strings.HasPrefix(opts, "layout=") {
+		layout = opts[len("layout="):]
+	}
+
+	return key, layout
+}
+
+// Represents a .env syntax variant.
+type Dialect int
+
+const (
+	Default Dialect = iota //This library's own syntax, fine-tuned by the decoder and encoder options.
+	Docker                 //`docker run --env-file`; values are taken verbatim, without quote or comment handling.
+	Systemd                //systemd `EnvironmentFile=`; shell-like quoting without interpolation.
+	Compose                //Docker Compose `.env` and `env_file`; quoting, escapes and interpolation.
+	POSIX                  //Files sourced by a POSIX shell; values are shell words.
+	Node                   //The `dotenv` npm package; single, double and backtick quotes, without interpolation.
+	Python                 //The `python-dotenv` package; braced-only interpolation.
+)
+
+// Represents where a `#` inside an unquoted value starts a comment.
+type Comments int
+
+const (
+	CommentsAnywhere   Comments = iota //Any `#` starts a comment.
+	CommentsAfterSpace                 //A `#` starts a comment only if it is preceded by whitespace.
+	CommentsNone                       //Comments may only take up whole lines.
+)
+
+// Represents how a key that stands alone, without `=` and a value, is read.
+type BareKeys int
+
+const (
+	BareKeysNone    BareKeys = iota //Bare keys are syntax errors.
+	BareKeysInherit                 //Bare keys take their value from the process environment, if it defines them.
+	BareKeysUnset                   //Bare keys are listed without a value.
+)
+
+// Represents the set of syntax rules followed by a dialect.
+type Rules struct {
+	Quotes          string   //Characters that may enclose a value; values are never unquoted if empty.
+	DoubleEscapes   string   //Characters that may follow a backslash in double-quoted values; a newline joins lines.
+	SingleEscapes   string   //Characters that may follow a backslash in single-quoted values.
+	UnquotedEscapes bool     //Whether a backslash takes the following character literally in unquoted values.
+	Interpolate     bool     //Whether unquoted and double-quoted values expand variable references.
+	BracedOnly      bool     //Whether only the braced `${VAR}` form of references is recognized.
+	Export          bool     //Whether the `export` keyword may precede a key.
+	InlineComments  Comments //Where a `#` inside an unquoted value starts a comment.
+	CommentChars    string   //Characters that start a comment line.
+	Continuation    bool     //Whether a trailing backslash joins the next line onto an unquoted value.
+	Heredoc         bool     //Whether `<<EOF` heredoc values are recognized.
+	TrimSpace       bool     //Whether whitespace around unquoted values is removed; values are taken verbatim otherwise.
+	KeySpace        bool     //Whether whitespace may appear between the key and the `=`.
+	Words           bool     //Whether unquoted values end at the first whitespace, like shell words.
+	BareKeys        BareKeys //How keys that stand alone, without `=` and a value, are read.
+}
+
+// The rule sets of each dialect.
+var rules = map[Dialect]Rules{
+	Default: {
+		Quotes:         `"'`,
+		DoubleEscapes:  `ntr"\$u`,
+		Interpolate:    true,
+		Export:         true,
+		InlineComments: CommentsAnywhere,
+		CommentChars:   "#",
+		Continuation:   true,
+		Heredoc:        true,
+		TrimSpace:      true,
+		KeySpace:       true,
+	},
+	Docker: {
+		InlineComments: CommentsNone,
+		CommentChars:   "#",
+		BareKeys:       BareKeysInherit,
+	},
+	Systemd: {
+		Quotes:          `"'`,
+		DoubleEscapes:   "\"\\`$\n",
+		UnquotedEscapes: true,
+		InlineComments:  CommentsNone,
+		CommentChars:    "#;",
+		Continuation:    true,
+		TrimSpace:       true,
+		KeySpace:        true,
+	},
+	Compose: {
+		Quotes:         `"'`,
+		DoubleEscapes:  `abfnrtv"\$`,
+		Interpolate:    true,
+		Export:         true,
+		InlineComments: CommentsAfterSpace,
+		CommentChars:   "#",
+		TrimSpace:      true,
+		KeySpace:       true,
+		BareKeys:       BareKeysInherit,
+	},
+	POSIX: {
+		Quotes:          `"'`,
+		DoubleEscapes:   "\"\\`$\n",
+		UnquotedEscapes: true,
+		Interpolate:     true,
+		Export:          true,
+		InlineComments:  CommentsAfterSpace,
+		CommentChars:    "#",
+		Continuation:    true,
+		TrimSpace:       true,
+		Words:           true,
+	},
+	Node: {
+		Quotes:         "\"'`",
+		DoubleEscapes:  "nr",
+		Export:         true,
+		InlineComments: CommentsAnywhere,
+		CommentChars:   "#",
+		TrimSpace:      true,
+		KeySpace:       true,
+	},
+	Python: {
+		Quotes:         `"'`,
+		DoubleEscapes:  `abfnrtv"'\`,
+		SingleEscapes:  `'\`,
+		Interpolate:    true,
+		BracedOnly:     true,
+		Export:         true,
+		InlineComments: CommentsAfterSpace,
+		CommentChars:   "#",
+		TrimSpace:      true,
+		KeySpace:       true,
+		BareKeys:       BareKeysUnset,
+	},
+}
+
+// Returns the syntax rules followed by the dialect. Unknown dialects follow the default rules.
+func (d Dialect) Rules() Rules {
+	if r, ok := rules[d]; ok {
+		return r
+	}
+
+	return rules[Default]
+}
+
+// Returns the name of the dialect.
+func (d Dialect) String() string {
+	switch d {
+	case Docker:
+		return "docker"
+	case Systemd:
+		return "systemd"
+	case Compose:
+		return "compose"
+	case POSIX:
+		return "posix"
+	case Node:
+		return "node"
+	case Python:
+		return "python"
+	}
+
+	return "default"
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"regexp"
 	"strings"
 	"unsafe"
 
+	"github.com/golobby/dotenv/v2/pkg/dialect"
 	"github.com/spf13/cast"
 )
 
@@ -24,6 +26,11 @@ func (e Encoder) Encode(structure interface{}) error {
 		return fmt.Errorf("no valid data sources could be found for the encoder")
 	}
 
+	//Ensure the selected dialect can represent the requested layout
+	if err := e.checkDialect(); err != nil {
+		return err
+	}
+
 	//Write the struct data to a map of strings
 	items, err := e.feed(structure)
 	if err != nil {
@@ -38,7 +45,10 @@ func (e Encoder) Encode(structure interface{}) error {
 			kvSep = " "
 		}
 		entry := item.Key + kvSep + "=" + kvSep + item.Value
-		lineDelim := "\n" //It is assumed that LF is ok on the host OS
+		if e.Opts.Export {
+			entry = "export " + entry
+		}
+		lineDelim := e.newline()
 
 		//Create the metadata line
 		meta := ""
@@ -60,7 +70,7 @@ func (e Encoder) Encode(structure interface{}) error {
 			metaPrefix := "# "
 			mdelim := ""
 			if e.Opts.IncludePath && e.Opts.IncludeTyping {
-				mdelim = "\n" + metaPrefix
+				mdelim = lineDelim + metaPrefix
 				if e.Opts.MinifyPTInfo {
 					mdelim = "; "
 				}
@@ -85,9 +95,34 @@ func (e Encoder) Encode(structure interface{}) error {
 		}
 	}
 
+	//Terminate the last line if requested
+	if e.Opts.TrailingNewline && len(items) > 0 {
+		if _, err := e.Dest.Write([]byte(e.newline())); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// newline returns the line terminator selected by the encoder options.
+func (e Encoder) newline() string {
+	if e.Opts.CRLF {
+		return "\r\n"
+	}
+
+	return "\n"
+}
+
+// keyPattern returns the pattern keys must match in strict mode.
+func (e Encoder) keyPattern() *regexp.Regexp {
+	if e.Opts.KeyPattern != nil {
+		return e.Opts.KeyPattern
+	}
+
+	return dialect.Identifier
+}
+
 // feed sets key/value pairs with the given struct fields.
 func (e Encoder) feed(structure interface{}) ([]_EnvLine, error) {
 	inputType := reflect.TypeOf(structure)
@@ -121,13 +156,21 @@ func (e Encoder) feedMap(s reflect.Value, path string, items *[]_EnvLine) error
 		//Check for the `env` struct tag
 		if t, exist := field.Tag.Lookup("env"); exist {
 			//Case 1: ordinary field; convert it to a string and save it to the map
-			strval, err := e.cast2String(fieldValue)
+			//Nil pointers stand for values that are not configured, and are left out
+			if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+				continue
+			}
+			key, layout := dialect.SplitTag(t)
+			if e.Opts.StrictKeys && !dialect.ValidKey(e.keyPattern(), key) {
+				return fmt.Errorf("key `%v` of field `%v` does not match the allowed pattern", key, field.Name)
+			}
+			strval, err := e.cast2String(fieldValue, layout)
 			if err != nil {
 				return fmt.Errorf("cannot convert field `%v` to string: %v", field.Name, err)
 			}
 			dt := fieldValue.Type().String()
 
-			*items = append(*items, _EnvLine{t, strval, dt, path + field.Name})
+			*items = append(*items, _EnvLine{key, strval, dt, path + field.Name})
 		} else if field.Type.Kind() == reflect.Struct || field.Type.Kind() == reflect.Ptr {
 			//Case 2/3: field is an embedded struct; recursively process it
 			/*
@@ -172,15 +215,45 @@ func (e Encoder) feedMap(s reflect.Value, path string, items *[]_EnvLine) error
 }
 
 // Utility to cast a reflected type into a string, including slices; uses `spf13/cast` internally.
-func (e Encoder) cast2String(v reflect.Value) (string, error) {
-	//Check for arrays and slices
+// Standard library types that casting does not handle are formatted by `formatStd`; times use the given layout.
+// The string is quoted if the decoder would otherwise alter it; slices are quoted as a whole, once joined.
+func (e Encoder) cast2String(v reflect.Value, layout string) (string, error) {
+	str, err := format(v, layout, e.Opts.SpacesInArrs)
+	if err != nil {
+		return "", err
+	}
+
+	return e.quote(str)
+}
+
+// format converts a reflected value into its unquoted string form; slices and arrays are joined with commas.
+func format(v reflect.Value, layout string, spaces bool) (string, error) {
+	if str, ok, err := formatStd(getRealValue(v), layout); ok {
+		return str, err
+	}
+
+	//Values whose pointer marshals them, such as `big.Int`, are formatted through their address
+	if kind := v.Kind(); kind != reflect.Ptr && kind != reflect.Interface && reflect.PtrTo(v.Type()).Implements(textMarshalerType) {
+		str, _, err := formatStd(reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Interface(), layout)
+		return str, err
+	}
+
+	//Dereference pointers; nil ones are written as empty values
 	kind := v.Kind()
+	if kind == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		return format(v.Elem(), layout, spaces)
+	}
+
+	//Check for arrays and slices
 	if kind == reflect.Slice || kind == reflect.Array {
 		//Process each item recursively
 		strs := make([]string, v.Len())
 		for i := 0; i < v.Len(); i++ {
 			var err error
-			strs[i], err = e.cast2String(v.Index(i))
+			strs[i], err = format(v.Index(i), layout, spaces)
 			if err != nil {
 				return "", err
 			}
@@ -188,26 +261,14 @@ func (e Encoder) cast2String(v reflect.Value) (string, error) {
 
 		//Emit the built array as a comma delimited string
 		sep := ","
-		if e.Opts.SpacesInArrs {
+		if spaces {
 			sep += " "
 		}
 		return strings.Join(strs, sep), nil
 	}
 
 	//Cast the item to a string
-	str, err := cast.ToStringE(getRealValue(v))
-	if err != nil {
-		return "", err
-	}
-
-	//Check if the string is prefixed and/or suffixed with spaces
-	//If so, quote the string and escape all double quotes
-	if strings.HasPrefix(str, " ") || strings.HasSuffix(str, " ") {
-		str = strings.ReplaceAll(str, "\"", "\\\"")
-		str = "\"" + str + "\""
-	}
-
-	return str, nil
+	return cast.ToStringE(getRealValue(v))
 }
 
 // Gets the value of a reflected field via `unsafe`. This allows processing of unexported fields.
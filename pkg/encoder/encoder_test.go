@@ -3,11 +3,20 @@ package encoder_test
 import (
 	"bytes"
 	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golobby/dotenv/v2"
-	//"github.com/stretchr/testify/assert"
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+	"github.com/golobby/dotenv/v2/pkg/encoder"
+	"github.com/stretchr/testify/assert"
 )
 
 // Inner struct (linked via pointer)
@@ -80,13 +89,260 @@ func TestSaveBytes(t *testing.T) {
 	for i, actual := range actuals {
 		fmt.Printf("L%02d: %s\n", i+1, actual)
 	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	//Values that the decoder only reads back correctly when they are quoted and escaped
+	src := cfg
+	src.QuoteBox.Quote1 = "back\\slash \"quoted\" # not a comment"
+	src.QuoteBox.Quote2 = "line 1\nline 2\r\n"
+	src.QuoteBox.Quote3 = "not a ${REFERENCE} or $REFERENCE"
+
+	buf := bytes.NewBuffer(nil)
+	if err := dotenv.NewEncoder(buf).Encode(&src); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{FlagBox: &FlagBox{}}
+	if err := dotenv.NewDecoder(buf).Decode(&c); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, src, c)
+}
+
+func TestSaveExport(t *testing.T) {
+	src := struct {
+		Host string `env:"DB_HOST"`
+		Port int    `env:"DB_PORT"`
+	}{"localhost", 5432}
+
+	buf := bytes.NewBuffer(nil)
+	enc := dotenv.NewEncoder(buf)
+	enc.Opts.Export = true
+	if err := enc.Encode(&src); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "export DB_HOST=localhost\nexport DB_PORT=5432", buf.String())
+
+	dst := src
+	dst.Host, dst.Port = "", 0
+	if err := dotenv.NewDecoder(buf).Decode(&dst); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, src, dst)
+}
+
+func TestSaveHeredoc(t *testing.T) {
+	src := struct {
+		Query string `env:"QUERY"`
+		Cert  string `env:"CERT"`
+		Plain string `env:"PLAIN"`
+	}{"SELECT *\n  FROM $TABLE", "BEGIN\nEOF\nEND\n", "single line"}
+
+	buf := bytes.NewBuffer(nil)
+	enc := dotenv.NewEncoder(buf)
+	enc.Opts.Heredoc = true
+	if err := enc.Encode(&src); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "QUERY=<<'EOF'\nSELECT *\n  FROM $TABLE\nEOF\nCERT=<<EOF_1\nBEGIN\nEOF\nEND\n\nEOF_1\nPLAIN=single line", buf.String())
+
+	dst := src
+	dst.Query, dst.Cert, dst.Plain = "", "", ""
+	if err := dotenv.NewDecoder(buf).Decode(&dst); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, src, dst)
+}
+
+func TestSaveLineEndings(t *testing.T) {
+	src := struct {
+		Query string `env:"QUERY"`
+		Port  int    `env:"PORT"`
+	}{"a\nb", 80}
+
+	buf := bytes.NewBuffer(nil)
+	enc := dotenv.NewEncoder(buf)
+	enc.Opts.CRLF = true
+	enc.Opts.TrailingNewline = true
+	enc.Opts.Heredoc = true
+	enc.Opts.IncludeTyping = true
+	if err := enc.Encode(&src); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "# Type: string\r\nQUERY=<<EOF\r\na\r\nb\r\nEOF\r\n\r\n# Type: int\r\nPORT=80\r\n", buf.String())
+
+	dst := src
+	dst.Query, dst.Port = "", 0
+	if err := dotenv.NewDecoder(buf).Decode(&dst); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, src, dst)
+
+	buf.Reset()
+	enc.Opts = encoder.DefaultOpts()
+	if err := enc.Encode(&src); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "QUERY=\"a\\nb\"\nPORT=80", buf.String())
+}
+
+func TestSaveDialects(t *testing.T) {
+	type Values struct {
+		Plain     string   `env:"PLAIN"`
+		Spaced    string   `env:"SPACED"`
+		Quotes    string   `env:"QUOTES"`
+		Comment   string   `env:"COMMENT"`
+		Reference string   `env:"REFERENCE"`
+		Backslash string   `env:"BACKSLASH"`
+		Multiline string   `env:"MULTILINE"`
+		List      []string `env:"LIST"`
+	}
+	src := Values{"plain", " spaced out ", `it's "quoted"`, "a #b", "${HOME} $HOME", `C:\path\n\`, "line 1\nline 2", []string{"a#b", "c"}}
 
-	/*
-		var c Config
-		if err := dotenv.NewDecoder(bytes).Decode(&c); err != nil {
-			t.Fatal(err)
+	dialects := []dialect.Dialect{dialect.Default, dialect.Systemd, dialect.Compose, dialect.POSIX, dialect.Node, dialect.Python}
+	for _, d := range dialects {
+		buf := bytes.NewBuffer(nil)
+		enc := dotenv.NewEncoder(buf)
+		enc.Opts.Dialect = d
+		if err := enc.Encode(&src); err != nil {
+			t.Fatal(d, err)
 		}
 
-		assert.Equal(t, cfg, c)
-	*/
+		dst := Values{}
+		dec := dotenv.NewDecoder(buf)
+		dec.Opts.Dialect = d
+		dec.Opts.UseEnv = false
+		if err := dec.Decode(&dst); err != nil {
+			t.Fatal(d, err)
+		}
+		assert.Equal(t, src, dst, d.String())
+	}
+}
+
+func TestSaveDialects_Unrepresentable(t *testing.T) {
+	src := struct {
+		Multiline string `env:"MULTILINE"`
+	}{"line 1\nline 2"}
+
+	enc := dotenv.NewEncoder(bytes.NewBuffer(nil))
+	enc.Opts.Dialect = dialect.Docker
+	err := enc.Encode(&src)
+	assert.EqualError(t, err, "cannot convert field `Multiline` to string: value \"line 1\\nline 2\" cannot be represented in the docker dialect")
+
+	enc.Opts.Export = true
+	err = enc.Encode(&src)
+	assert.EqualError(t, err, "dotenv encode: the docker dialect does not support the `export` keyword")
+}
+
+func TestSaveStrictKeys(t *testing.T) {
+	src := struct {
+		Valid   string `env:"APP_NAME"`
+		Dotted  string `env:"app.name"`
+		Spacing string `env:"APP NAME"`
+	}{"a", "b", "c"}
+
+	//Keys are written as-is by default
+	buf := bytes.NewBuffer(nil)
+	enc := dotenv.NewEncoder(buf)
+	assert.NoError(t, enc.Encode(&src))
+	assert.Equal(t, "APP_NAME=a\napp.name=b\nAPP NAME=c", buf.String())
+
+	enc.Opts.StrictKeys = true
+	err := enc.Encode(&src)
+	assert.EqualError(t, err, "key `app.name` of field `Dotted` does not match the allowed pattern")
+
+	enc.Opts.KeyPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_.]*`)
+	err = enc.Encode(&src)
+	assert.EqualError(t, err, "key `APP NAME` of field `Spacing` does not match the allowed pattern")
+
+	enc.Opts.KeyPattern = regexp.MustCompile(`APP_NAME|app\.name|APP NAME|APP`)
+	assert.NoError(t, enc.Encode(&src))
+}
+
+func TestSaveStdTypes(t *testing.T) {
+	type Std struct {
+		Timeout  time.Duration   `env:"TIMEOUT"`
+		Retries  []time.Duration `env:"RETRIES"`
+		Started  time.Time       `env:"STARTED"`
+		Day      time.Time       `env:"DAY,layout=Mon, 02 Jan 2006"`
+		Home     url.URL         `env:"HOME"`
+		API      *url.URL        `env:"API"`
+		IP       net.IP          `env:"IP"`
+		Subnet   net.IPNet       `env:"SUBNET"`
+		Addr     netip.Addr      `env:"ADDR"`
+		Prefixes []netip.Prefix  `env:"PREFIXES"`
+		Pattern  *regexp.Regexp  `env:"PATTERN"`
+		Big      *big.Int        `env:"BIG"`
+		Total    big.Int         `env:"TOTAL"`
+		Mode     os.FileMode     `env:"MODE"`
+	}
+
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/8")
+	big, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	src := Std{
+		Timeout:  30 * time.Second,
+		Retries:  []time.Duration{time.Second, 90 * time.Minute},
+		Started:  time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Day:      time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Home:     url.URL{Scheme: "https", Host: "example.com", Path: "/home"},
+		API:      &url.URL{Scheme: "http", Host: "localhost:8080"},
+		IP:       net.ParseIP("192.168.0.1"),
+		Subnet:   *subnet,
+		Addr:     netip.MustParseAddr("::1"),
+		Prefixes: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")},
+		Pattern:  regexp.MustCompile(`^v[0-9]+$`),
+		Big:      big,
+		Total:    *big,
+		Mode:     0640,
+	}
+
+	//Values are written the way they are usually spelled, rather than as the numbers or structs behind them
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dotenv.NewEncoder(buf).Encode(&src))
+	assert.Equal(t, strings.Join([]string{
+		"TIMEOUT=30s",
+		"RETRIES=1s, 1h30m0s",
+		"STARTED=2024-05-01T12:30:00Z",
+		"DAY=Wed, 01 May 2024",
+		"HOME=https://example.com/home",
+		"API=http://localhost:8080",
+		"IP=192.168.0.1",
+		"SUBNET=10.0.0.0/8",
+		"ADDR=::1",
+		"PREFIXES=10.0.0.0/8, fd00::/8",
+		`PATTERN="^v[0-9]+\$"`,
+		"BIG=123456789012345678901234567890",
+		"TOTAL=123456789012345678901234567890",
+		"MODE=0640",
+	}, "\n"), buf.String())
+
+	dst := Std{}
+	assert.NoError(t, dotenv.NewDecoder(buf).Decode(&dst))
+	assert.Equal(t, src.Pattern.String(), dst.Pattern.String())
+	src.Pattern, dst.Pattern = nil, nil
+	assert.Equal(t, src, dst)
+}
+
+func TestSaveOptionalPointers(t *testing.T) {
+	port, debug := 0, false
+	src := struct {
+		Port  *int      `env:"PORT"`
+		Debug *bool     `env:"DEBUG"`
+		Name  *string   `env:"NAME"`
+		API   *url.URL  `env:"API"`
+		Hosts *[]string `env:"HOSTS"`
+	}{Port: &port, Debug: &debug}
+
+	//Nil pointers are left out, and others are written as the values they point to
+	buf := bytes.NewBuffer(nil)
+	assert.NoError(t, dotenv.NewEncoder(buf).Encode(&src))
+	assert.Equal(t, "PORT=0\nDEBUG=false", buf.String())
+
+	hosts := []string{"a", "b"}
+	src.Hosts = &hosts
+	buf.Reset()
+	assert.NoError(t, dotenv.NewEncoder(buf).Encode(&src))
+	assert.Equal(t, "PORT=0\nDEBUG=false\nHOSTS=a, b", buf.String())
 }
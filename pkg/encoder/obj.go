@@ -1,10 +1,25 @@
 package encoder
 
+import (
+	"regexp"
+
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+)
+
 // Represents a set of options for the encoder.
 type EncoderOpts struct {
+	Dialect dialect.Dialect //The syntax variant to write; values are quoted so that the dialect reads them back exactly.
+
 	SpacesInArrs        bool //Whether to put spaces after commas in arrays.
 	SpaceAroundKV       bool //Whether to put spaces around the keys and values.
 	BlankLinesBetweenKV bool //Whether to include blank lines between entries.
+	Export              bool //Whether to prefix entries with the `export` keyword so the file can be sourced by a shell.
+	Heredoc             bool //Whether to write values containing newlines as heredoc (`<<EOF`) blocks instead of escaped strings.
+	CRLF                bool //Whether to terminate lines with CRLF (Windows) instead of LF.
+	TrailingNewline     bool //Whether to terminate the last line of the file.
+
+	StrictKeys bool           //Whether to refuse keys from `env` tags that do not match `KeyPattern`.
+	KeyPattern *regexp.Regexp //Pattern keys must match in full in strict mode; POSIX identifiers (`dialect.Identifier`) if nil.
 
 	IncludePath   bool //Whether to write the path to the element in the resultant dotenv.
 	IncludeTyping bool //Whether to write the datatype of the element in the resultant dotenv.
@@ -22,7 +37,9 @@ type _EnvLine struct {
 // Returns the default options for the encoder.
 func DefaultOpts() EncoderOpts {
 	return EncoderOpts{
-		true, false, false,
+		dialect.Default,
+		true, false, false, false, false, false, false,
+		false, nil,
 		false, false, false,
 	}
 }
@@ -0,0 +1,149 @@
+package encoder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+)
+
+// checkDialect ensures the encoder options only ask for syntax that the selected dialect understands.
+func (e Encoder) checkDialect() error {
+	r := e.Opts.Dialect.Rules()
+	if e.Opts.Export && !r.Export {
+		return fmt.Errorf("dotenv encode: the %v dialect does not support the `export` keyword", e.Opts.Dialect)
+	}
+	if e.Opts.SpaceAroundKV && !(r.KeySpace && r.TrimSpace) {
+		return fmt.Errorf("dotenv encode: the %v dialect does not support spaces around `=`", e.Opts.Dialect)
+	}
+
+	return nil
+}
+
+// quote encloses the string in quotes if the selected dialect would otherwise alter it when reading it back.
+// The dialect's quote styles are tried in order; an error is returned if none of them can represent the string.
+func (e Encoder) quote(str string) (string, error) {
+	r := e.Opts.Dialect.Rules()
+	if e.Opts.Heredoc && r.Heredoc && strings.Contains(str, "\n") && !strings.Contains(str, "\r") {
+		return heredoc(str, e.newline()), nil
+	}
+	if !needsQuotes(str, r) {
+		return str, nil
+	}
+
+	for i := 0; i < len(r.Quotes); i++ {
+		if quoted, ok := quoteWith(str, r.Quotes[i], r, e.newline()); ok {
+			return quoted, nil
+		}
+	}
+
+	return "", fmt.Errorf("value %q cannot be represented in the %v dialect", str, e.Opts.Dialect)
+}
+
+// needsQuotes reports whether the dialect would read the string differently if it were written unquoted.
+func needsQuotes(str string, r dialect.Rules) bool {
+	switch {
+	case str == "":
+		return false
+	case strings.ContainsAny(str, "\n\r"):
+		return true
+	case r.TrimSpace && strings.TrimSpace(str) != str:
+		return true
+	case strings.ContainsAny(str, r.Quotes):
+		return true
+	case r.InlineComments != dialect.CommentsNone && strings.Contains(str, "#"):
+		return true
+	case r.Interpolate && strings.Contains(str, "$"):
+		return true
+	case r.UnquotedEscapes && strings.Contains(str, "\\"):
+		return true
+	case r.Continuation && strings.HasSuffix(str, "\\"):
+		return true
+	case r.Heredoc && strings.HasPrefix(str, "<<"):
+		return true
+	case r.Words && strings.ContainsAny(str, " \t"):
+		return true
+	}
+
+	return false
+}
+
+// quoteWith encloses the string in the given quote character, escaping what the dialect requires.
+// It returns false if the dialect cannot escape a character that would otherwise be misread.
+func quoteWith(str string, q byte, r dialect.Rules, newline string) (string, bool) {
+	escapes := ""
+	switch q {
+	case '"':
+		escapes = r.DoubleEscapes
+	case '\'':
+		escapes = r.SingleEscapes
+	}
+	escapable := func(c byte) bool {
+		return strings.IndexByte(escapes, c) >= 0
+	}
+
+	var sb strings.Builder
+	sb.WriteByte(q)
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case c == q || (c == '\\' && escapes != ""):
+			if !escapable(c) {
+				return "", false
+			}
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == '$' && q == '"' && r.Interpolate:
+			if !escapable('$') {
+				return "", false
+			}
+			sb.WriteString("\\$")
+		case c == '\n':
+			//Quoted values may span lines, unless the escape is available
+			if escapable('n') {
+				sb.WriteString("\\n")
+			} else {
+				sb.WriteString(newline)
+			}
+		case c == '\r':
+			//Line endings are normalized by the decoder, so a carriage return must be escaped
+			if !escapable('r') {
+				return "", false
+			}
+			sb.WriteString("\\r")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte(q)
+
+	return sb.String(), true
+}
+
+// heredoc wraps a multi-line string in a heredoc block, picking a delimiter that does not occur as a line of the string.
+// The delimiter is quoted if the string contains references, so that the decoder reads the body verbatim.
+func heredoc(str string, newline string) string {
+	lines := strings.Split(str, "\n")
+	delim := "EOF"
+	for n := 1; ; n++ {
+		clash := false
+		for _, l := range lines {
+			if l == delim {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			break
+		}
+		delim = fmt.Sprintf("EOF_%v", n)
+	}
+
+	marker := delim
+	if strings.Contains(str, "$") {
+		marker = "'" + delim + "'"
+	}
+
+	body := strings.Join(lines, newline)
+	return "<<" + marker + newline + body + newline + delim
+}
@@ -0,0 +1,55 @@
+package encoder
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// The `encoding.TextMarshaler` interface, for types that only marshal through a pointer.
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// formatStd formats values of standard library types and of types implementing `encoding.TextMarshaler` the way the
+// decoder reads them back, returning false for other types. Times are formatted with the given layout, or as RFC 3339 if it is empty.
+func formatStd(x interface{}, layout string) (string, bool, error) {
+	//Nil pointers stand for empty values
+	if v := reflect.ValueOf(x); v.Kind() == reflect.Ptr && v.IsNil() {
+		switch x.(type) {
+		case *url.URL, *net.IPNet, *regexp.Regexp, encoding.TextMarshaler:
+			return "", true, nil
+		}
+	}
+
+	switch v := x.(type) {
+	case time.Duration:
+		return v.String(), true, nil
+	case time.Time:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return v.Format(layout), true, nil
+	case url.URL:
+		return v.String(), true, nil
+	case *url.URL:
+		return v.String(), true, nil
+	case net.IPNet:
+		return v.String(), true, nil
+	case *net.IPNet:
+		return v.String(), true, nil
+	case *regexp.Regexp:
+		return v.String(), true, nil
+	case os.FileMode:
+		//In octal, the way the decoder reads them back
+		return fmt.Sprintf("%#o", uint32(v)), true, nil
+	case encoding.TextMarshaler:
+		b, err := v.MarshalText()
+		return string(b), true, err
+	}
+
+	return "", false, nil
+}
@@ -0,0 +1,66 @@
+package lexer
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf16"
+)
+
+// Byte order marks recognized at the start of a data source.
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF32LE = []byte{0xFF, 0xFE, 0x00, 0x00}
+	bomUTF32BE = []byte{0x00, 0x00, 0xFE, 0xFF}
+)
+
+// normalize converts the raw content of a data source to BOM-less UTF-8 with LF line endings.
+// UTF-16 sources are transcoded, whether or not they start with a BOM; UTF-32 sources are rejected.
+func normalize(b []byte) ([]byte, error) {
+	var err error
+	switch {
+	case bytes.HasPrefix(b, bomUTF32LE), bytes.HasPrefix(b, bomUTF32BE):
+		return nil, errors.New("dotenv: UTF-32 encoded files are not supported; please convert the file to UTF-8")
+	case bytes.HasPrefix(b, bomUTF8):
+		b = b[len(bomUTF8):]
+	case bytes.HasPrefix(b, bomUTF16LE):
+		b, err = transcodeUTF16(b[len(bomUTF16LE):], false)
+	case bytes.HasPrefix(b, bomUTF16BE):
+		b, err = transcodeUTF16(b[len(bomUTF16BE):], true)
+	case len(b) >= 2 && b[0] == 0 && b[1] != 0:
+		//No BOM, but the first character is ASCII encoded on two bytes; assume UTF-16
+		b, err = transcodeUTF16(b, true)
+	case len(b) >= 2 && b[0] != 0 && b[1] == 0:
+		b, err = transcodeUTF16(b, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//Convert CRLF (Windows) and lone CR (classic Mac) line endings to LF
+	if bytes.IndexByte(b, '\r') >= 0 {
+		b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+		b = bytes.ReplaceAll(b, []byte("\r"), []byte("\n"))
+	}
+
+	return b, nil
+}
+
+// transcodeUTF16 converts a UTF-16 byte sequence, without its BOM, to UTF-8.
+func transcodeUTF16(b []byte, bigEndian bool) ([]byte, error) {
+	if len(b)%2 != 0 {
+		return nil, errors.New("dotenv: malformed UTF-16 file; odd number of bytes")
+	}
+
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			units[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+
+	return []byte(string(utf16.Decode(units))), nil
+}
@@ -0,0 +1,82 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Short descriptions of the syntax errors reported by the scanner.
+const (
+	reasonMissingKey          = "missing key"
+	reasonMissingEquals       = "missing `=` after the key"
+	reasonKeySpace            = "whitespace in key"
+	reasonInvalidKey          = "key does not match the allowed pattern"
+	reasonUnterminatedQuote   = "unterminated quote"
+	reasonTrailingText        = "unexpected text after the value"
+	reasonUnterminatedHeredoc = "unterminated heredoc"
+)
+
+// Represents a syntax error in a dot env (.env) data source. Use `errors.As` to retrieve it from the scanner's or decoder's errors.
+type SyntaxError struct {
+	File   string //Name of the data source, if known.
+	Line   int    //Line of the error, starting at 1.
+	Column int    //Column of the error in bytes, starting at 1.
+	Text   string //The offending line.
+	Reason string //Short description of the error, e.g. "unterminated quote".
+}
+
+// Error describes the error, followed by an excerpt of the offending line with a caret under the error's column.
+func (e *SyntaxError) Error() string {
+	loc := fmt.Sprintf("line %v, column %v", e.Line, e.Column)
+	if e.File != "" {
+		loc = fmt.Sprintf("%v:%v:%v", e.File, e.Line, e.Column)
+	}
+
+	//Align the caret with the column, keeping tabs so that it lines up whatever their width
+	pad := strings.Builder{}
+	for i, c := range e.Text {
+		if i >= e.Column-1 {
+			break
+		}
+		if c == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+
+	num := fmt.Sprint(e.Line)
+	gutter := strings.Repeat(" ", len(num))
+	return fmt.Sprintf("dotenv: syntax error at %v: %v\n %v | %v\n %v | %v^", loc, e.Reason, num, e.Text, gutter, pad.String())
+}
+
+// Represents a syntax error found by parse, positioned relative to the start of the logical line it was given.
+type _ParseError struct {
+	Offset int
+	Reason string
+}
+
+func (e *_ParseError) Error() string {
+	return "dotenv: " + e.Reason
+}
+
+// Represents a physical line that is part of a logical line being parsed.
+type _Segment struct {
+	Offset int    //Position of the segment in the logical line.
+	Line   int    //Line number of the physical line.
+	Trim   int    //Number of leading bytes of the physical line that were dropped when joining it.
+	At     int    //Offset of the physical line in the data source.
+	Text   []byte //The physical line.
+}
+
+// locate converts a parse error into a syntax error pointing into the physical line that contains it.
+func (s *Scanner) locate(pe *_ParseError) *SyntaxError {
+	seg := s.segment(pe.Offset)
+	return &SyntaxError{
+		File:   s.Name,
+		Line:   seg.Line,
+		Column: pe.Offset - seg.Offset + seg.Trim + 1,
+		Text:   string(seg.Text),
+		Reason: pe.Reason,
+	}
+}
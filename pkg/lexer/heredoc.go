@@ -0,0 +1,119 @@
+package lexer
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Matches the opening marker of a heredoc value: `<<EOF`, `<<-EOF`, `<<'EOF'` or `<<"EOF"`.
+var heredocRe = regexp.MustCompile(`^<<(-?)(?:([A-Za-z_][A-Za-z0-9_]*)|'([A-Za-z_][A-Za-z0-9_]*)'|"([A-Za-z_][A-Za-z0-9_]*)")$`)
+
+// Represents a heredoc value whose body is still being read.
+type _Heredoc struct {
+	Delim  string
+	Strip  bool   //Whether the marker was `<<-`; the common indentation of the body and terminator is removed.
+	Quoted bool   //Whether the delimiter was quoted; the body is then taken verbatim, without interpolation.
+	Line   int    //Line that opened the heredoc.
+	Text   []byte //The line that opened the heredoc.
+	Body   [][]byte
+}
+
+// openHeredoc checks whether the given unquoted value opens a heredoc, and if so, returns its reader state.
+func openHeredoc(value []byte, quote byte, line int, text []byte) *_Heredoc {
+	if quote != 0 || !bytes.HasPrefix(value, []byte("<<")) {
+		return nil
+	}
+
+	m := heredocRe.FindSubmatch(value)
+	if m == nil {
+		return nil
+	}
+
+	return &_Heredoc{
+		Delim:  string(m[2]) + string(m[3]) + string(m[4]),
+		Strip:  len(m[1]) > 0,
+		Quoted: len(m[2]) == 0,
+		Line:   line,
+		Text:   text,
+	}
+}
+
+// feed adds a physical line to the heredoc. It returns true if the line was the closing delimiter.
+// The line is kept as a subslice of the data source; it is only copied once the body is complete.
+func (h *_Heredoc) feed(line []byte) bool {
+	term := line
+	if h.Strip {
+		term = bytes.TrimLeft(term, " \t")
+	}
+	if string(term) == h.Delim {
+		return true
+	}
+
+	h.Body = append(h.Body, line)
+	return false
+}
+
+// quote returns the quote style the heredoc's body is treated like: a quoted delimiter takes the body verbatim.
+func (h *_Heredoc) quote() byte {
+	if h.Quoted {
+		return '\''
+	}
+
+	return 0
+}
+
+// body joins the lines of a completely read heredoc.
+func (h *_Heredoc) body() string {
+	body := h.Body
+	if h.Strip {
+		body = dedent(body)
+	}
+
+	//Join the body lines with a single allocation
+	n := len(body)
+	for _, l := range body {
+		n += len(l)
+	}
+	var sb strings.Builder
+	sb.Grow(n)
+	for i, l := range body {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.Write(l)
+	}
+
+	return sb.String()
+}
+
+// dedent removes the longest run of leading whitespace shared by all non-blank lines.
+func dedent(lines [][]byte) [][]byte {
+	var prefix []byte
+	first := true
+	for _, l := range lines {
+		if len(bytes.TrimSpace(l)) == 0 {
+			continue
+		}
+
+		indent := l[:len(l)-len(bytes.TrimLeft(l, " \t"))]
+		if first {
+			prefix = indent
+			first = false
+			continue
+		}
+		for !bytes.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	out := make([][]byte, len(lines))
+	for i, l := range lines {
+		out[i] = bytes.TrimPrefix(l, prefix)
+		if len(bytes.TrimSpace(out[i])) == 0 {
+			out[i] = nil
+		}
+	}
+
+	return out
+}
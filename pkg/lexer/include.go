@@ -0,0 +1,29 @@
+package lexer
+
+import "regexp"
+
+// Matches an include directive: `#include path` or `@include path`, with a `?` after `include` for the optional form.
+// The path may be quoted to keep surrounding whitespace or a `#`.
+var includeRe = regexp.MustCompile(`^[ \t]*([#@]include(\?)?)[ \t]+(?:"([^"]*)"|'([^']*)'|([^ \t"'].*?))[ \t]*$`)
+
+// include checks whether the given line is an include directive, and if so, returns its parts.
+func include(line []byte) (_Entry, bool) {
+	m := includeRe.FindSubmatchIndex(line)
+	if m == nil {
+		return _Entry{}, false
+	}
+
+	e := _Entry{Export: -1, Eq: -1, IncludeAt: m[2], IncludeEnd: m[1], Optional: m[4] >= 0}
+	for g := 3; g <= 5; g++ {
+		if m[2*g] >= 0 {
+			e.Include = line[m[2*g]:m[2*g+1]]
+		}
+	}
+
+	//Trailing whitespace is not part of the directive
+	for e.IncludeEnd > 0 && isSpace(line[e.IncludeEnd-1]) {
+		e.IncludeEnd--
+	}
+
+	return e, true
+}
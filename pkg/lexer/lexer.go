@@ -0,0 +1,667 @@
+// Package lexer splits dot env (.env) data sources into tokens, following the syntax rules of a dialect.
+package lexer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+)
+
+// Represents the kind of a token.
+type Kind int
+
+const (
+	Blank           Kind = iota //A line holding nothing but whitespace.
+	Comment                     //A comment, either on a line of its own or following a value.
+	Export                      //The `export` keyword preceding a key.
+	Key                         //The key of an entry; a key standing alone is not followed by an assignment and a value.
+	Assign                      //The `=` separating a key from its value.
+	Value                       //The value of an entry; its text is the raw value, without its quotes and with escapes left in place.
+	Heredoc                     //A heredoc value; its text is the body, without the opening marker and closing delimiter.
+	Include                     //An include directive, if enabled; its text is the path of the included file.
+	OptionalInclude             //An include directive whose file may be missing, if enabled; its text is the path of the included file.
+	Section                     //The name in a section header `[name]`, if enabled.
+	Extends                     //The name of the section a section header inherits from, as in `[name : parent]`.
+)
+
+// String returns the name of the token kind.
+func (k Kind) String() string {
+	switch k {
+	case Blank:
+		return "blank"
+	case Comment:
+		return "comment"
+	case Export:
+		return "export"
+	case Key:
+		return "key"
+	case Assign:
+		return "assign"
+	case Value:
+		return "value"
+	case Heredoc:
+		return "heredoc"
+	case Include:
+		return "include"
+	case OptionalInclude:
+		return "optional include"
+	case Section:
+		return "section"
+	case Extends:
+		return "extends"
+	}
+
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Represents a position in a data source.
+type Position struct {
+	Offset int //Offset in bytes, counted after byte order marks and CR line endings have been removed.
+	Line   int //Line number, starting at 1.
+	Column int //Column in bytes, starting at 1.
+}
+
+// Represents a token read from a data source.
+type Token struct {
+	Kind  Kind
+	Text  string   //Text of the token; see the kinds for values and heredocs.
+	Quote byte     //Quote character that enclosed a value, or 0; a heredoc with a quoted delimiter reports `'`.
+	Pos   Position //Start of the token; a quoted value starts at its opening quote, a heredoc at its `<<` marker.
+	End   Position //Position just past the token; a quoted value ends after its closing quote, a heredoc after its delimiter.
+}
+
+// Reads the tokens of a dot env (.env) data source, one logical line at a time.
+// The source is buffered in full on the first call to `Scan`, and normalized to BOM-less UTF-8 with LF line endings;
+// set `MaxBytes` to bound the memory this takes for untrusted sources.
+type Scanner struct {
+	Name          string //Name of the data source, e.g. its file path; used in error messages.
+	MaxBytes      int64  //Maximum size of the data source in bytes; 0 means unlimited.
+	MaxLineLength int    //Maximum length of a physical line in bytes; 0 means unlimited.
+
+	KeyPattern *regexp.Regexp //Pattern every key must match in full, e.g. `dialect.Identifier`; keys are not checked if nil.
+	Includes   bool           //Whether `#include path` and `@include path` lines, or `#include? path` and `@include? path`, are read as include directives.
+	Sections   bool           //Whether `[name]` and `[name : parent]` lines are read as section headers.
+	Lenient    bool           //Whether logical lines with syntax errors are skipped, and listed by `Skipped`, instead of stopping the scanner.
+
+	OmitCommentText bool //Whether comments on lines of their own are returned without their text, sparing a copy for callers that ignore them.
+
+	rdr    io.Reader
+	rules  dialect.Rules
+	loaded bool
+	src    []byte
+	pos    int //Offset of the next physical line.
+	line   int //Number of the last physical line read.
+
+	toks []Token //Tokens of the last logical line read.
+	next int     //Position in `toks` of the next token to return.
+	tok  Token
+	err  error
+
+	skipped []*SyntaxError //Syntax errors of the lines skipped in lenient mode.
+
+	segs []_Segment //Physical lines making up the logical line being read.
+	buf  []byte     //Backing storage for logical lines joined by a continuation.
+}
+
+// NewScanner creates a scanner that reads the given data source with the given syntax rules.
+// The data source is read to its end, and held in memory, before the first token is returned.
+func NewScanner(src io.Reader, rules dialect.Rules) *Scanner {
+	return &Scanner{rdr: src, rules: rules}
+}
+
+// Scan advances to the next token, which is then available through `Token`.
+// It returns false at the end of the data source or on the first error, which is then available through `Err`.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	if !s.loaded {
+		if s.err = s.load(); s.err != nil {
+			return false
+		}
+	}
+
+	for s.next >= len(s.toks) {
+		if s.pos >= len(s.src) {
+			return false
+		}
+		if s.err = s.entry(); s.err != nil && !s.skip() {
+			return false
+		}
+	}
+
+	s.tok = s.toks[s.next]
+	s.next++
+	return true
+}
+
+// Token returns the token read by the last call to `Scan`.
+func (s *Scanner) Token() Token {
+	return s.tok
+}
+
+// Err returns the first error encountered by the scanner; syntax errors are of type `*SyntaxError`.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Lines returns the physical lines of the source that the given token spans, without copying them.
+// The token must have been read by this scanner.
+func (s *Scanner) Lines(t Token) []byte {
+	from := t.Pos.Offset - (t.Pos.Column - 1)
+	to := t.End.Offset
+	if n := bytes.IndexByte(s.src[to:], '\n'); n >= 0 {
+		to += n
+	} else {
+		to = len(s.src)
+	}
+
+	return s.src[from:to]
+}
+
+// Skipped returns the syntax errors of the logical lines skipped so far in lenient mode, in source order.
+func (s *Scanner) Skipped() []*SyntaxError {
+	return s.skipped
+}
+
+// skip drops the logical line that failed to parse if the scanner is lenient, and clears the error.
+// Scanning resumes on the physical line after the one the failed line started on, so that a quote or heredoc
+// left open does not swallow the rest of the source.
+func (s *Scanner) skip() bool {
+	se, ok := s.err.(*SyntaxError)
+	if !ok || !s.Lenient {
+		return false
+	}
+
+	s.skipped = append(s.skipped, se)
+	if len(s.segs) > 0 {
+		first := s.segs[0]
+		s.pos = first.At + len(first.Text) + 1
+		if s.pos > len(s.src) {
+			s.pos = len(s.src)
+		}
+		s.line = first.Line
+	}
+	s.toks = s.toks[:0]
+	s.next = 0
+	s.err = nil
+	return true
+}
+
+// load reads in and normalizes the data source.
+func (s *Scanner) load() error {
+	s.loaded = true
+
+	//Refuse sources larger than the configured limit without reading them in full
+	dat := s.rdr
+	if s.MaxBytes > 0 {
+		dat = io.LimitReader(dat, s.MaxBytes+1)
+	}
+
+	raw, err := io.ReadAll(dat)
+	if err != nil {
+		return fmt.Errorf("dotenv: error when reading file; err: %v", err)
+	}
+	//The limit applies to the source as stored, before line endings are unified or UTF-16 is transcoded
+	if s.MaxBytes > 0 && int64(len(raw)) > s.MaxBytes {
+		return fmt.Errorf("dotenv: file exceeds the maximum size of %v bytes", s.MaxBytes)
+	}
+
+	//Strip byte order marks, transcode UTF-16 and unify line endings before scanning
+	src, err := normalize(raw)
+	if err != nil {
+		return fmt.Errorf("dotenv: error when reading file; err: %v", err)
+	}
+
+	s.src = src
+	return nil
+}
+
+// cut returns the next physical line of the source, without copying it, along with its offset.
+func (s *Scanner) cut() (int, []byte, error) {
+	at := s.pos
+	text := s.src[at:]
+	if n := bytes.IndexByte(text, '\n'); n >= 0 {
+		text = text[:n]
+		s.pos += n + 1
+	} else {
+		s.pos = len(s.src)
+	}
+
+	s.line++
+	if s.MaxLineLength > 0 && len(text) > s.MaxLineLength {
+		return 0, nil, fmt.Errorf("dotenv: line %v exceeds the maximum length of %v bytes", s.line, s.MaxLineLength)
+	}
+
+	return at, text, nil
+}
+
+// entry reads the next logical line of the source and queues its tokens.
+func (s *Scanner) entry() error {
+	s.toks = s.toks[:0]
+	s.next = 0
+	s.segs = s.segs[:0]
+
+	//Quoted values may span several physical lines, and so may unquoted values ending in a backslash
+	//`entry` holds the logical line being read: a window into the source that widens over a multiline quote,
+	//or a copy in `buf` once a continuation has joined lines
+	var entry []byte
+	from := s.pos
+	cont, owned := false, false
+	var open *_ParseError
+	for s.pos < len(s.src) {
+		at, text, err := s.cut()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case len(s.segs) == 0:
+			entry = text
+			s.segs = append(s.segs, _Segment{0, s.line, 0, at, text})
+		case cont:
+			joined := bytes.TrimLeft(text, " \t")
+			s.segs = append(s.segs, _Segment{len(entry), s.line, len(text) - len(joined), at, text})
+			if !owned {
+				entry = append(s.buf[:0], entry...)
+				owned = true
+			}
+			entry = append(entry, joined...)
+			s.buf = entry
+		default:
+			s.segs = append(s.segs, _Segment{len(entry) + 1, s.line, 0, at, text})
+			if owned {
+				entry = append(append(entry, '\n'), text...)
+				s.buf = entry
+			} else {
+				entry = s.src[from : at+len(text)]
+			}
+
+			//A quote that stays open across a line without its quote character cannot have been closed
+			if bytes.IndexByte(text, entry[open.Offset]) < 0 {
+				continue
+			}
+		}
+		cont = false
+
+		e, err := s.parse(entry)
+		if pe, ok := err.(*_ParseError); ok && pe.Reason == reasonUnterminatedQuote {
+			open = pe
+			continue
+		} else if err != nil {
+			return s.locate(err.(*_ParseError))
+		} else if h := openHeredoc(e.Value, e.Quote, s.segs[0].Line, text); h != nil && s.rules.Heredoc {
+			//The value is a heredoc; its body follows on the next lines
+			return s.heredoc(e, h)
+		} else if e.Key != nil && e.Quote == 0 && s.rules.Continuation && continues(e.Value, s.rules) &&
+			bytes.HasSuffix(bytes.TrimRight(entry, " \t"), []byte{'\\'}) {
+			//Drop the backslash and join the next physical line onto the value
+			entry = bytes.TrimRight(entry, " \t")
+			entry = entry[:len(entry)-1]
+			cont = true
+			continue
+		}
+
+		s.emit(e)
+		return nil
+	}
+
+	//The file ended while a quoted value was still open
+	if !cont {
+		return s.locate(open)
+	}
+
+	//The file ended right after a line continuation; keep what has been read so far
+	e, err := s.parse(entry)
+	if err != nil {
+		return s.locate(err.(*_ParseError))
+	}
+	s.emit(e)
+	return nil
+}
+
+// parse splits a logical line into its parts, checking its key against the key pattern and looking for directives.
+func (s *Scanner) parse(line []byte) (_Entry, error) {
+	if s.Includes && len(s.segs) == 1 {
+		if e, ok := include(line); ok {
+			return e, nil
+		}
+	}
+	if s.Sections && len(s.segs) == 1 {
+		if e, ok := section(line); ok {
+			return e, nil
+		}
+	}
+
+	e, err := parse(line, s.rules)
+	if err == nil && e.Key != nil && s.KeyPattern != nil && !dialect.ValidKey(s.KeyPattern, string(e.Key)) {
+		return e, &_ParseError{e.KeyAt, reasonInvalidKey}
+	}
+
+	return e, err
+}
+
+// heredoc reads the body of a heredoc value up to its closing delimiter and queues the tokens of its entry.
+func (s *Scanner) heredoc(e _Entry, h *_Heredoc) error {
+	for s.pos < len(s.src) {
+		_, text, err := s.cut()
+		if err != nil {
+			return err
+		}
+		if !h.feed(text) {
+			continue
+		}
+
+		//The value token covers everything from the marker to the end of the closing delimiter
+		s.emit(e)
+		for i := range s.toks {
+			if t := &s.toks[i]; t.Kind == Value {
+				t.Kind = Heredoc
+				t.Text = h.body()
+				t.Quote = h.quote()
+				t.End = Position{s.pos, s.line, len(text) + 1}
+				if s.pos > 0 && s.src[s.pos-1] == '\n' {
+					t.End.Offset--
+				}
+			}
+		}
+		return nil
+	}
+
+	//The file ended before the heredoc's closing delimiter
+	return s.locate(&_ParseError{e.ValueAt, fmt.Sprintf("%v; missing `%v`", reasonUnterminatedHeredoc, h.Delim)})
+}
+
+// emit queues the tokens of a parsed logical line.
+func (s *Scanner) emit(e _Entry) {
+	if e.Include != nil {
+		kind := Include
+		if e.Optional {
+			kind = OptionalInclude
+		}
+		s.toks = append(s.toks, s.token(kind, string(e.Include), 0, e.IncludeAt, e.IncludeEnd))
+		return
+	}
+	if e.Section != nil {
+		s.toks = append(s.toks, s.token(Section, string(e.Section), 0, e.SectionAt, e.SectionAt+len(e.Section)))
+		if e.Parent != nil {
+			s.toks = append(s.toks, s.token(Extends, string(e.Parent), 0, e.ParentAt, e.ParentAt+len(e.Parent)))
+		}
+		if e.Comment != nil {
+			s.toks = append(s.toks, s.token(Comment, string(e.Comment), 0, e.CommentAt, e.CommentAt+len(e.Comment)))
+		}
+		return
+	}
+
+	if e.Key == nil && e.Comment == nil {
+		end := 0
+		for _, seg := range s.segs {
+			end = seg.Offset + len(seg.Text) - seg.Trim
+		}
+		s.toks = append(s.toks, s.token(Blank, "", 0, 0, end))
+		return
+	}
+
+	if e.Export >= 0 {
+		s.toks = append(s.toks, s.token(Export, "export", 0, e.Export, e.Export+len("export")))
+	}
+	if e.Key != nil {
+		s.toks = append(s.toks, s.token(Key, string(e.Key), 0, e.KeyAt, e.KeyAt+len(e.Key)))
+	}
+	if e.Eq >= 0 {
+		s.toks = append(s.toks,
+			s.token(Assign, "=", 0, e.Eq, e.Eq+1),
+			s.token(Value, string(e.Value), e.Quote, e.ValueAt, e.ValueEnd),
+		)
+	}
+	if e.Comment != nil {
+		text := ""
+		if e.Key != nil || !s.OmitCommentText {
+			text = string(e.Comment)
+		}
+		s.toks = append(s.toks, s.token(Comment, text, 0, e.CommentAt, e.CommentAt+len(e.Comment)))
+	}
+}
+
+// token builds a token spanning the given offsets of the logical line being read.
+func (s *Scanner) token(kind Kind, text string, quote byte, from, to int) Token {
+	end := s.position(from)
+	if to > from {
+		end = s.position(to - 1)
+		end.Offset++
+		end.Column++
+	}
+
+	return Token{kind, text, quote, s.position(from), end}
+}
+
+// segment returns the physical line that contains the given offset of the logical line being read.
+func (s *Scanner) segment(off int) _Segment {
+	seg := s.segs[0]
+	for _, sg := range s.segs[1:] {
+		if sg.Offset <= off {
+			seg = sg
+		}
+	}
+
+	return seg
+}
+
+// position converts an offset into the logical line being read into a position in the data source.
+func (s *Scanner) position(off int) Position {
+	seg := s.segment(off)
+	col := off - seg.Offset + seg.Trim
+	return Position{seg.At + col, seg.Line, col + 1}
+}
+
+// continues reports whether an unquoted value ends in a backslash that joins the next line onto it.
+// If backslashes escape characters in unquoted values, the last one must not be escaped itself.
+func continues(v []byte, r dialect.Rules) bool {
+	n := len(v) - len(bytes.TrimRight(v, "\\"))
+	if r.UnquotedEscapes {
+		return n%2 == 1
+	}
+
+	return n > 0
+}
+
+// Represents the parts of a logical line; positions are offsets into the line.
+type _Entry struct {
+	Export    int    //Position of the `export` keyword, or -1.
+	Key       []byte //The key; nil if the line holds no entry.
+	KeyAt     int
+	Eq        int    //Position of the `=`, or -1 if the key stands alone.
+	Value     []byte //The raw value, without its quotes.
+	ValueAt   int    //Position of the value, including its opening quote.
+	ValueEnd  int    //Position just past the value, including its closing quote.
+	Quote     byte   //Quote character that enclosed the value, or 0.
+	Comment   []byte //The comment on the line, if any.
+	CommentAt int
+
+	Include    []byte //The path of an include directive; nil if the line is not one.
+	IncludeAt  int
+	IncludeEnd int
+	Optional   bool //Whether the included file may be missing.
+
+	Section   []byte //The name of a section header; nil if the line is not one.
+	SectionAt int
+	Parent    []byte //The section the header's section inherits from, if any.
+	ParentAt  int
+}
+
+// parse splits the given dot env (.env) logical line into its parts, which are subslices of the line.
+// Escape sequences are left in place; they are processed together with interpolation by the decoder.
+// Syntax errors are returned as a `*_ParseError` positioned relative to the start of the line.
+func parse(line []byte, r dialect.Rules) (_Entry, error) {
+	e := _Entry{Export: -1, Eq: -1}
+	ln := bytes.TrimLeftFunc(line, unicode.IsSpace)
+	base := len(line) - len(ln)
+	if r.TrimSpace {
+		ln = bytes.TrimRightFunc(ln, unicode.IsSpace)
+	}
+
+	//Blank lines and comments hold no entry
+	if len(ln) == 0 {
+		return e, nil
+	}
+	if strings.IndexByte(r.CommentChars, ln[0]) >= 0 {
+		e.Comment, e.CommentAt = ln, base
+		return e, nil
+	}
+
+	//Strip the `export` keyword used by files that are also sourced by a shell
+	if r.Export && bytes.HasPrefix(ln, []byte("export")) && len(ln) > 6 && (ln[6] == ' ' || ln[6] == '\t') {
+		e.Export = base
+		rest := bytes.TrimLeft(ln[6:], " \t")
+		base += len(ln) - len(rest)
+		ln = rest
+	}
+
+	//Split the key from the value; a comment before the `=` leaves the line without a value
+	eq := bytes.IndexByte(ln, '=')
+	if eq < 0 {
+		return bare(e, ln, base, r)
+	}
+	if i := bytes.IndexByte(ln[:eq], '#'); r.InlineComments == dialect.CommentsAnywhere && i >= 0 {
+		return e, &_ParseError{base + i, reasonMissingEquals}
+	}
+	key := ln[:eq]
+	if r.KeySpace {
+		key = bytes.TrimRight(key, " \t")
+	}
+	if len(key) == 0 {
+		return e, &_ParseError{base + eq, reasonMissingKey}
+	}
+	if i := bytes.IndexAny(key, " \t"); !r.KeySpace && i >= 0 {
+		return e, &_ParseError{base + i, reasonKeySpace}
+	}
+	val := ln[eq+1:]
+	voff := base + eq + 1
+
+	//Shell words end at the first whitespace; anything but a comment may not follow the (empty) value
+	if r.Words && len(val) > 0 && isSpace(val[0]) {
+		rest := bytes.TrimLeft(val, " \t")
+		if len(rest) > 0 && rest[0] != '#' {
+			return e, &_ParseError{voff + len(val) - len(rest), reasonTrailingText}
+		}
+		if len(rest) > 0 {
+			e.Comment, e.CommentAt = rest, voff+len(val)-len(rest)
+		}
+		e.Key, e.KeyAt, e.Eq = key, base, base+eq
+		e.Value, e.ValueAt, e.ValueEnd = val[:0], voff, voff
+		return e, nil
+	}
+	if r.TrimSpace {
+		rest := bytes.TrimLeft(val, " \t")
+		voff += len(val) - len(rest)
+		val = rest
+	}
+
+	//Quoted value: find the closing quote, skipping over escaped characters
+	if len(val) > 0 && strings.IndexByte(r.Quotes, val[0]) >= 0 {
+		end := closingQuote(val, r)
+		if end < 0 {
+			return e, &_ParseError{voff, reasonUnterminatedQuote}
+		}
+
+		//Shells would take any text following the quote as a command; other dialects ignore it
+		after := val[end+1:]
+		rest := bytes.TrimLeft(after, " \t")
+		if r.Words && len(rest) > 0 && rest[0] != '#' {
+			return e, &_ParseError{voff + end + 1 + len(after) - len(rest), reasonTrailingText}
+		}
+		if len(rest) > 0 && rest[0] == '#' {
+			e.Comment, e.CommentAt = rest, voff+len(val)-len(rest)
+		}
+
+		e.Key, e.KeyAt, e.Eq = key, base, base+eq
+		e.Value, e.ValueAt, e.ValueEnd, e.Quote = val[1:end], voff, voff+end+1, val[0]
+		return e, nil
+	}
+
+	//Unquoted value: cut off the inline comment, if any
+	cut := -1
+	switch r.InlineComments {
+	case dialect.CommentsAnywhere:
+		cut = bytes.IndexByte(val, '#')
+	case dialect.CommentsAfterSpace:
+		//The character preceding the value is the `=`, or whitespace that was trimmed
+		for i := 0; i < len(val); i++ {
+			if val[i] == '#' && ((i == 0 && voff > base+eq+1) || (i > 0 && isSpace(val[i-1]))) {
+				cut = i
+				break
+			}
+		}
+	}
+	if cut >= 0 {
+		e.Comment, e.CommentAt = val[cut:], voff+cut
+		val = val[:cut]
+	}
+	if r.TrimSpace {
+		val = bytes.TrimRight(val, " \t")
+	}
+
+	//Unescaped whitespace may not remain inside a shell word
+	if r.Words {
+		for i := 0; i < len(val); i++ {
+			if val[i] == '\\' {
+				i++
+			} else if isSpace(val[i]) {
+				rest := bytes.TrimLeft(val[i:], " \t")
+				return e, &_ParseError{voff + len(val) - len(rest), reasonTrailingText}
+			}
+		}
+	}
+
+	e.Key, e.KeyAt, e.Eq = key, base, base+eq
+	e.Value, e.ValueAt, e.ValueEnd = val, voff, voff+len(val)
+	return e, nil
+}
+
+// bare reads a line without a `=`, which holds a key standing alone if the dialect allows it; a comment may follow the key.
+func bare(e _Entry, ln []byte, base int, r dialect.Rules) (_Entry, error) {
+	n := bytes.IndexAny(ln, " \t")
+	if n < 0 {
+		n = len(ln)
+	}
+	if i := bytes.IndexByte(ln[:n], '#'); r.InlineComments == dialect.CommentsAnywhere && i >= 0 {
+		n = i
+	}
+
+	rest := bytes.TrimLeft(ln[n:], " \t")
+	if r.BareKeys == dialect.BareKeysNone || n == 0 || (len(rest) > 0 && rest[0] != '#') {
+		return e, &_ParseError{base + len(ln), reasonMissingEquals}
+	}
+
+	e.Key, e.KeyAt, e.Eq = ln[:n], base, -1
+	if len(rest) > 0 {
+		e.Comment, e.CommentAt = rest, base+len(ln)-len(rest)
+	}
+	return e, nil
+}
+
+// closingQuote returns the position of the quote that closes the quoted value at the start of the given slice, or -1.
+// Backslashes skip over the following character if the quote style supports escape sequences.
+func closingQuote(val []byte, r dialect.Rules) int {
+	q := val[0]
+	escapes := (q == '"' && r.DoubleEscapes != "") || (q == '\'' && r.SingleEscapes != "")
+	for i := 1; i < len(val); i++ {
+		if val[i] == '\\' && escapes {
+			i++
+		} else if val[i] == q {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isSpace reports whether the given byte is a space or a tab.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
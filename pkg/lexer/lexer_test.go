@@ -0,0 +1,181 @@
+package lexer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golobby/dotenv/v2/pkg/dialect"
+	"github.com/golobby/dotenv/v2/pkg/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+// scan reads all tokens of the given source with the given dialect.
+func scan(src string, d dialect.Dialect) ([]lexer.Token, error) {
+	s := lexer.NewScanner(strings.NewReader(src), d.Rules())
+	toks := []lexer.Token{}
+	for s.Scan() {
+		toks = append(toks, s.Token())
+	}
+
+	return toks, s.Err()
+}
+
+func pos(off, line, col int) lexer.Position {
+	return lexer.Position{Offset: off, Line: line, Column: col}
+}
+
+func TestScanner(t *testing.T) {
+	src := "# Header\n\nexport A=1 # one\nB = \"x\ny\"\nC=foo \\\n  bar\nD=<<EOF\n  body\nEOF\nE=''\n"
+	toks, err := scan(src, dialect.Default)
+	assert.NoError(t, err)
+
+	expected := []lexer.Token{
+		{lexer.Comment, "# Header", 0, pos(0, 1, 1), pos(8, 1, 9)},
+		{lexer.Blank, "", 0, pos(9, 2, 1), pos(9, 2, 1)},
+		{lexer.Export, "export", 0, pos(10, 3, 1), pos(16, 3, 7)},
+		{lexer.Key, "A", 0, pos(17, 3, 8), pos(18, 3, 9)},
+		{lexer.Assign, "=", 0, pos(18, 3, 9), pos(19, 3, 10)},
+		{lexer.Value, "1", 0, pos(19, 3, 10), pos(20, 3, 11)},
+		{lexer.Comment, "# one", 0, pos(21, 3, 12), pos(26, 3, 17)},
+		{lexer.Key, "B", 0, pos(27, 4, 1), pos(28, 4, 2)},
+		{lexer.Assign, "=", 0, pos(29, 4, 3), pos(30, 4, 4)},
+		{lexer.Value, "x\ny", '"', pos(31, 4, 5), pos(36, 5, 3)},
+		{lexer.Key, "C", 0, pos(37, 6, 1), pos(38, 6, 2)},
+		{lexer.Assign, "=", 0, pos(38, 6, 2), pos(39, 6, 3)},
+		{lexer.Value, "foo bar", 0, pos(39, 6, 3), pos(50, 7, 6)},
+		{lexer.Key, "D", 0, pos(51, 8, 1), pos(52, 8, 2)},
+		{lexer.Assign, "=", 0, pos(52, 8, 2), pos(53, 8, 3)},
+		{lexer.Heredoc, "  body", 0, pos(53, 8, 3), pos(69, 10, 4)},
+		{lexer.Key, "E", 0, pos(70, 11, 1), pos(71, 11, 2)},
+		{lexer.Assign, "=", 0, pos(71, 11, 2), pos(72, 11, 3)},
+		{lexer.Value, "", '\'', pos(72, 11, 3), pos(74, 11, 5)},
+	}
+	assert.Equal(t, expected, toks)
+
+	//Token positions point into the source
+	for _, tok := range toks {
+		if tok.Kind != lexer.Value && tok.Kind != lexer.Heredoc {
+			assert.Equal(t, tok.Text, src[tok.Pos.Offset:tok.End.Offset])
+		}
+	}
+
+	//Tokens span whole physical lines
+	s := lexer.NewScanner(strings.NewReader(src), dialect.Default.Rules())
+	for s.Scan() && s.Token().Kind != lexer.Heredoc {
+	}
+	assert.Equal(t, "D=<<EOF\n  body\nEOF", string(s.Lines(s.Token())))
+
+	//Comments on lines of their own may be read without their text
+	s = lexer.NewScanner(strings.NewReader(src), dialect.Default.Rules())
+	s.OmitCommentText = true
+	comments := []string{}
+	for s.Scan() {
+		if s.Token().Kind == lexer.Comment {
+			comments = append(comments, s.Token().Text)
+		}
+	}
+	assert.Equal(t, []string{"", "# one"}, comments)
+}
+
+func TestScanner_Dialects(t *testing.T) {
+	//Docker takes values verbatim, without quote or comment handling
+	toks, err := scan("A=\"x\" # y\n", dialect.Docker)
+	assert.NoError(t, err)
+	if assert.Len(t, toks, 3) {
+		assert.Equal(t, lexer.Value, toks[2].Kind)
+		assert.Equal(t, "\"x\" # y", toks[2].Text)
+		assert.Equal(t, byte(0), toks[2].Quote)
+	}
+
+	//POSIX values are shell words; a comment may follow them
+	toks, err = scan("A= # y\n", dialect.POSIX)
+	assert.NoError(t, err)
+	if assert.Len(t, toks, 4) {
+		assert.Equal(t, "", toks[2].Text)
+		assert.Equal(t, lexer.Comment, toks[3].Kind)
+		assert.Equal(t, "# y", toks[3].Text)
+	}
+}
+
+func TestScanner_Errors(t *testing.T) {
+	s := lexer.NewScanner(strings.NewReader("A=1\nB='x\n"), dialect.Default.Rules())
+	s.Name = "test.env"
+
+	//Tokens before the error are still returned
+	kinds := []lexer.Kind{}
+	for s.Scan() {
+		kinds = append(kinds, s.Token().Kind)
+	}
+	assert.Equal(t, []lexer.Kind{lexer.Key, lexer.Assign, lexer.Value}, kinds)
+
+	var se *lexer.SyntaxError
+	if assert.ErrorAs(t, s.Err(), &se) {
+		assert.Equal(t, lexer.SyntaxError{File: "test.env", Line: 2, Column: 3, Text: "B='x", Reason: "unterminated quote"}, *se)
+	}
+	assert.False(t, s.Scan())
+
+	//Lenient scanners skip the lines in error, resuming after the line an open quote or heredoc started on
+	s = lexer.NewScanner(strings.NewReader("A=1\nB='x\nC=2\nD=<<EOF\nE=3\n=4\n"), dialect.Default.Rules())
+	s.Lenient = true
+	keys := []string{}
+	for s.Scan() {
+		if s.Token().Kind == lexer.Key {
+			keys = append(keys, s.Token().Text)
+		}
+	}
+	assert.NoError(t, s.Err())
+	assert.Equal(t, []string{"A", "C", "E"}, keys)
+	lines := []int{}
+	for _, se := range s.Skipped() {
+		lines = append(lines, se.Line)
+	}
+	assert.Equal(t, []int{2, 4, 6}, lines)
+
+	//Limits are enforced by the scanner
+	s = lexer.NewScanner(strings.NewReader("A=12345\n"), dialect.Default.Rules())
+	s.MaxLineLength = 4
+	assert.False(t, s.Scan())
+	assert.EqualError(t, s.Err(), "dotenv: line 1 exceeds the maximum length of 4 bytes")
+}
+
+func TestScanner_Includes(t *testing.T) {
+	src := "#include ./shared.env\n  @include? \"../my file.env\"  \n#include\nA=1\n"
+
+	//Directives are comments or syntax errors unless enabled
+	_, err := scan(src, dialect.Default)
+	assert.Error(t, err)
+
+	s := lexer.NewScanner(strings.NewReader(src), dialect.Default.Rules())
+	s.Includes = true
+	toks := []lexer.Token{}
+	for s.Scan() {
+		toks = append(toks, s.Token())
+	}
+	assert.NoError(t, s.Err())
+	if assert.Len(t, toks, 6) {
+		assert.Equal(t, lexer.Token{lexer.Include, "./shared.env", 0, pos(0, 1, 1), pos(21, 1, 22)}, toks[0])
+		assert.Equal(t, lexer.Token{lexer.OptionalInclude, "../my file.env", 0, pos(24, 2, 3), pos(50, 2, 29)}, toks[1])
+		assert.Equal(t, lexer.Comment, toks[2].Kind)
+	}
+}
+
+func TestScanner_Sections(t *testing.T) {
+	src := "[production]\n  [ staging : production ] # inherits\n[bad section]\n"
+
+	s := lexer.NewScanner(strings.NewReader(src), dialect.Default.Rules())
+	s.Sections = true
+	toks := []lexer.Token{}
+	for s.Scan() {
+		toks = append(toks, s.Token())
+	}
+	if assert.Len(t, toks, 4) {
+		assert.Equal(t, lexer.Token{lexer.Section, "production", 0, pos(1, 1, 2), pos(11, 1, 12)}, toks[0])
+		assert.Equal(t, lexer.Token{lexer.Section, "staging", 0, pos(17, 2, 5), pos(24, 2, 12)}, toks[1])
+		assert.Equal(t, lexer.Token{lexer.Extends, "production", 0, pos(27, 2, 15), pos(37, 2, 25)}, toks[2])
+		assert.Equal(t, lexer.Comment, toks[3].Kind)
+	}
+
+	//Headers that are not section names are syntax errors
+	var se *lexer.SyntaxError
+	assert.ErrorAs(t, s.Err(), &se)
+}
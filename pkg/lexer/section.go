@@ -0,0 +1,24 @@
+package lexer
+
+import "regexp"
+
+// Matches a section header: `[name]`, or `[name : parent]` for a section that inherits from another; a comment may follow.
+var sectionRe = regexp.MustCompile(`^[ \t]*\[[ \t]*([A-Za-z0-9_.\-]+)[ \t]*(?::[ \t]*([A-Za-z0-9_.\-]+)[ \t]*)?\][ \t]*(#.*)?$`)
+
+// section checks whether the given line is a section header, and if so, returns its parts.
+func section(line []byte) (_Entry, bool) {
+	m := sectionRe.FindSubmatchIndex(line)
+	if m == nil {
+		return _Entry{}, false
+	}
+
+	e := _Entry{Export: -1, Eq: -1, Section: line[m[2]:m[3]], SectionAt: m[2]}
+	if m[4] >= 0 {
+		e.Parent, e.ParentAt = line[m[4]:m[5]], m[4]
+	}
+	if m[6] >= 0 {
+		e.Comment, e.CommentAt = line[m[6]:m[7]], m[6]
+	}
+
+	return e, true
+}